@@ -0,0 +1,226 @@
+package affiliation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	// BotFieldName matches rules against the identity name
+	BotFieldName = "name"
+	// BotFieldUsername matches rules against the identity username
+	BotFieldUsername = "username"
+	// BotFieldEmail matches rules against the identity email
+	BotFieldEmail = "email"
+	// BotFieldSource matches rules against the identity source
+	BotFieldSource = "source"
+
+	// DefaultBotThreshold is the score from which an identity is considered a bot
+	DefaultBotThreshold = 0.5
+)
+
+// BotRule scores an identity when Pattern matches Field, restricted to Source when set
+type BotRule struct {
+	Field   string  `json:"field"`
+	Pattern string  `json:"pattern"`
+	Source  string  `json:"source,omitempty"`
+	Weight  float64 `json:"weight"`
+}
+
+// BotConfig configures the bot classifier, Allow and Deny entries are matched against uuid, username and email
+type BotConfig struct {
+	Rules     []BotRule `json:"rules"`
+	Allow     []string  `json:"allow"`
+	Deny      []string  `json:"deny"`
+	Threshold float64   `json:"threshold"`
+}
+
+// DefaultBotRules are the rules used when the configuration has none
+var DefaultBotRules = []BotRule{
+	{Field: BotFieldName, Pattern: `(?i)\[bot\]`, Weight: 0.9},
+	{Field: BotFieldName, Pattern: `(?i)(^|[\s_-])bot$`, Weight: 0.7},
+	{Field: BotFieldUsername, Pattern: `(?i)\[bot\]$`, Weight: 0.95},
+	{Field: BotFieldUsername, Pattern: `(?i)[-_]bot$`, Weight: 0.7},
+	{Field: BotFieldUsername, Pattern: `(?i)^(dependabot|renovate|greenkeeper|snyk-bot|github-actions|codecov|coveralls|k8s-ci-robot|mergify)`, Weight: 0.95},
+	{Field: BotFieldEmail, Pattern: `(?i)(^|[.+_-])(no-?reply|do-?not-?reply)@`, Weight: 0.6},
+	{Field: BotFieldEmail, Pattern: `(?i)(dependabot|renovate|github-actions)`, Weight: 0.9},
+	{Field: BotFieldEmail, Pattern: `(?i)(^|[.+_-])bot@`, Weight: 0.6},
+	{Field: BotFieldSource, Pattern: `(?i)^(jenkins|circleci|travis)$`, Weight: 0.3},
+}
+
+// BotCandidate contains the identity fields used by the bot classifier
+type BotCandidate struct {
+	UUID     string
+	Name     string
+	Username string
+	Email    string
+	Source   string
+}
+
+// BotScore is the result of a bot classification
+type BotScore struct {
+	Score   float64  `json:"score"`
+	IsBot   bool     `json:"is_bot"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+type compiledBotRule struct {
+	BotRule
+	re *regexp.Regexp
+}
+
+// BotClassifier scores identities by name, username, email and source patterns
+type BotClassifier struct {
+	rules     []compiledBotRule
+	allow     map[string]struct{}
+	deny      map[string]struct{}
+	threshold float64
+}
+
+// NewBotClassifier creates a classifier, DefaultBotRules and DefaultBotThreshold are used when not configured
+func NewBotClassifier(config BotConfig) (*BotClassifier, error) {
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = DefaultBotRules
+	}
+	threshold := config.Threshold
+	if threshold <= 0 {
+		threshold = DefaultBotThreshold
+	}
+
+	c := &BotClassifier{
+		allow:     make(map[string]struct{}, len(config.Allow)),
+		deny:      make(map[string]struct{}, len(config.Deny)),
+		threshold: threshold,
+	}
+	for _, rule := range rules {
+		switch rule.Field {
+		case BotFieldName, BotFieldUsername, BotFieldEmail, BotFieldSource:
+		default:
+			return nil, fmt.Errorf("NewBotClassifier: unknown field %q", rule.Field)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("NewBotClassifier: invalid pattern %q: %s", rule.Pattern, err)
+		}
+		c.rules = append(c.rules, compiledBotRule{BotRule: rule, re: re})
+	}
+	for _, entry := range config.Allow {
+		c.allow[strings.ToLower(strings.TrimSpace(entry))] = struct{}{}
+	}
+	for _, entry := range config.Deny {
+		c.deny[strings.ToLower(strings.TrimSpace(entry))] = struct{}{}
+	}
+
+	return c, nil
+}
+
+// LoadBotClassifier creates a classifier from a JSON BotConfig
+func LoadBotClassifier(r io.Reader) (*BotClassifier, error) {
+	var config BotConfig
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+
+	return NewBotClassifier(config)
+}
+
+// Classify scores a candidate, matching rules are combined so that each one raises the score toward 1
+func (c *BotClassifier) Classify(candidate BotCandidate) *BotScore {
+	for _, key := range []string{candidate.UUID, candidate.Username, candidate.Email} {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		if _, ok := c.deny[key]; ok {
+			return &BotScore{Score: 1, IsBot: true, Reasons: []string{"deny list: " + key}}
+		}
+		if _, ok := c.allow[key]; ok {
+			return &BotScore{Score: 0, IsBot: false, Reasons: []string{"allow list: " + key}}
+		}
+	}
+
+	result := &BotScore{}
+	notBot := 1.0
+	for _, rule := range c.rules {
+		if rule.Source != "" && !strings.EqualFold(rule.Source, candidate.Source) {
+			continue
+		}
+		value := candidate.field(rule.Field)
+		if value == "" || !rule.re.MatchString(value) {
+			continue
+		}
+		notBot *= 1 - rule.Weight
+		result.Reasons = append(result.Reasons, fmt.Sprintf("%s %q matches %s", rule.Field, value, rule.Pattern))
+	}
+	result.Score = 1 - notBot
+	result.IsBot = result.Score >= c.threshold
+
+	return result
+}
+
+// ClassifyIdentity scores an affiliation identity
+func (c *BotClassifier) ClassifyIdentity(identity *IdentityData) *BotScore {
+	if identity == nil {
+		return &BotScore{}
+	}
+	candidate := BotCandidate{Source: identity.Source}
+	if identity.UUID != nil {
+		candidate.UUID = *identity.UUID
+	}
+	if identity.Name != nil {
+		candidate.Name = *identity.Name
+	}
+	if identity.Username != nil {
+		candidate.Username = *identity.Username
+	}
+	if identity.Email != nil {
+		candidate.Email = *identity.Email
+	}
+
+	return c.Classify(candidate)
+}
+
+// Flag sets IsBot on an identity the affiliation service has not curated, curated identities are left untouched.
+// An AffIdentity has no source, the source rules match source, the one the identity was found in, when not empty.
+func (c *BotClassifier) Flag(identity *AffIdentity, source string) *BotScore {
+	if identity == nil || identity.IsBot != nil {
+		return nil
+	}
+	candidate := BotCandidate{
+		Name:     identity.Name,
+		Username: identity.Username,
+		Email:    identity.Email,
+		Source:   source,
+	}
+	if identity.UUID != nil {
+		candidate.UUID = *identity.UUID
+	}
+
+	score := c.Classify(candidate)
+	var isBot int64
+	if score.IsBot {
+		isBot = 1
+	}
+	identity.IsBot = &isBot
+
+	return score
+}
+
+func (b BotCandidate) field(name string) string {
+	switch name {
+	case BotFieldName:
+		return b.Name
+	case BotFieldUsername:
+		return b.Username
+	case BotFieldEmail:
+		return b.Email
+	case BotFieldSource:
+		return b.Source
+	}
+
+	return ""
+}
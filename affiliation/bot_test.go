@@ -0,0 +1,70 @@
+package affiliation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotClassifier(t *testing.T) {
+	classifier, err := NewBotClassifier(BotConfig{
+		Allow: []string{"robot-fan"},
+		Deny:  []string{"release-manager@example.com"},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		candidate BotCandidate
+		isBot     bool
+	}{
+		{BotCandidate{Username: "dependabot[bot]", Source: "github"}, true},
+		{BotCandidate{Name: "CI Bot", Email: "ci-bot@example.com"}, true},
+		{BotCandidate{Name: "John Smith", Username: "jsmith", Email: "jsmith@example.com"}, false},
+		{BotCandidate{Email: "noreply@example.com"}, true},
+		{BotCandidate{Name: "Jane Doe", Email: "jdoe@users.noreply.github.com"}, false},
+		{BotCandidate{Username: "robot-fan", Name: "[bot] fan"}, false},
+		{BotCandidate{Name: "Release Manager", Email: "Release-Manager@example.com"}, true},
+		{BotCandidate{Email: "bot@example.com"}, true},
+		// surnames ending in bot are not bots
+		{BotCandidate{Name: "Jane Talbot", Username: "jtalbot", Email: "jane.talbot@example.com"}, false},
+		{BotCandidate{Name: "Sam Abbot", Email: "abbot@example.com"}, false},
+		{BotCandidate{Name: "Ann Cabot", Username: "acabot", Email: "cabot@example.com"}, false},
+	}
+	for _, test := range tests {
+		score := classifier.Classify(test.candidate)
+		assert.Equal(t, test.isBot, score.IsBot, "%+v: %+v", test.candidate, score)
+	}
+}
+
+func TestBotClassifierConfig(t *testing.T) {
+	classifier, err := LoadBotClassifier(strings.NewReader(`{"rules":[{"field":"username","pattern":"^svc-","source":"gerrit","weight":0.8}]}`))
+	assert.NoError(t, err)
+	assert.True(t, classifier.Classify(BotCandidate{Username: "svc-build", Source: "gerrit"}).IsBot)
+	assert.False(t, classifier.Classify(BotCandidate{Username: "svc-build", Source: "github"}).IsBot)
+
+	_, err = NewBotClassifier(BotConfig{Rules: []BotRule{{Field: "phone", Pattern: "x"}}})
+	assert.Error(t, err)
+}
+
+func TestBotClassifierFlag(t *testing.T) {
+	classifier, _ := NewBotClassifier(BotConfig{})
+
+	identity := &AffIdentity{Name: "renovate[bot]", Username: "renovate[bot]"}
+	score := classifier.Flag(identity, "")
+	assert.True(t, score.IsBot)
+	assert.Equal(t, int64(1), *identity.IsBot)
+
+	// the source rules apply to the source given
+	classifier, _ = NewBotClassifier(BotConfig{Rules: []BotRule{{Field: BotFieldUsername, Pattern: "^svc-", Source: "gerrit", Weight: 0.8}}})
+	identity = &AffIdentity{Username: "svc-build"}
+	assert.False(t, classifier.Flag(identity, "github").IsBot)
+	identity = &AffIdentity{Username: "svc-build"}
+	assert.True(t, classifier.Flag(identity, "gerrit").IsBot)
+	assert.Equal(t, int64(1), *identity.IsBot)
+
+	curated := int64(0)
+	identity = &AffIdentity{Username: "dependabot", IsBot: &curated}
+	assert.Nil(t, classifier.Flag(identity, "github"))
+	assert.Equal(t, int64(0), *identity.IsBot)
+}
@@ -0,0 +1,202 @@
+package affiliation
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+const (
+	// ExactDomainConfidence is the confidence of an organization found by an exact email domain match
+	ExactDomainConfidence = 0.9
+	// WildcardDomainConfidence is the confidence of an organization found by a wildcard subdomain match
+	WildcardDomainConfidence = 0.7
+)
+
+// DefaultPublicDomains are webmail domains that never identify an organization
+var DefaultPublicDomains = []string{
+	"126.com",
+	"163.com",
+	"aol.com",
+	"fastmail.com",
+	"gmail.com",
+	"gmx.com",
+	"gmx.de",
+	"googlemail.com",
+	"hotmail.com",
+	"icloud.com",
+	"live.com",
+	"mac.com",
+	"mail.ru",
+	"me.com",
+	"msn.com",
+	"outlook.com",
+	"proton.me",
+	"protonmail.com",
+	"qq.com",
+	"users.noreply.github.com",
+	"yahoo.com",
+	"yandex.ru",
+	"zoho.com",
+}
+
+// DomainConfig is the domain to organization table, keys may be exact domains or wildcards like *.example.com
+type DomainConfig struct {
+	Domains       map[string]string `json:"domains"`
+	PublicDomains []string          `json:"public_domains"`
+}
+
+// DomainMatch is the result of an organization inference from an email domain
+type DomainMatch struct {
+	Domain     string  `json:"domain"`
+	OrgName    string  `json:"org_name,omitempty"`
+	Pattern    string  `json:"pattern,omitempty"`
+	Confidence float64 `json:"confidence"`
+	Public     bool    `json:"public"`
+}
+
+// DomainMatcher infers identities organizations from their email domain
+type DomainMatcher struct {
+	exact          map[string]string
+	wildcards      map[string]string
+	publicExact    map[string]struct{}
+	publicWildcard map[string]struct{}
+}
+
+// NewDomainMatcher creates a matcher from a domain to organization table, DefaultPublicDomains are used when publicDomains is nil
+func NewDomainMatcher(domains map[string]string, publicDomains []string) *DomainMatcher {
+	if publicDomains == nil {
+		publicDomains = DefaultPublicDomains
+	}
+	m := &DomainMatcher{
+		exact:          make(map[string]string),
+		wildcards:      make(map[string]string),
+		publicExact:    make(map[string]struct{}),
+		publicWildcard: make(map[string]struct{}),
+	}
+	for domain, org := range domains {
+		domain = normalizeDomain(domain)
+		if strings.HasPrefix(domain, "*.") {
+			parent := strings.TrimPrefix(domain, "*.")
+			m.wildcards[parent] = org
+			continue
+		}
+		m.exact[domain] = org
+	}
+	for _, domain := range publicDomains {
+		domain = normalizeDomain(domain)
+		if strings.HasPrefix(domain, "*.") {
+			m.publicWildcard[strings.TrimPrefix(domain, "*.")] = struct{}{}
+			continue
+		}
+		m.publicExact[domain] = struct{}{}
+	}
+
+	return m
+}
+
+// LoadDomainMatcher creates a matcher from a JSON DomainConfig
+func LoadDomainMatcher(r io.Reader) (*DomainMatcher, error) {
+	var config DomainConfig
+	if err := json.NewDecoder(r).Decode(&config); err != nil {
+		return nil, err
+	}
+
+	return NewDomainMatcher(config.Domains, config.PublicDomains), nil
+}
+
+// EmailDomain returns the lower cased domain of a valid email, or an empty string
+func EmailDomain(email string) string {
+	email = strings.TrimSpace(email)
+	if !emailRegex.MatchString(email) {
+		return ""
+	}
+
+	return normalizeDomain(email[strings.LastIndex(email, "@")+1:])
+}
+
+// Match infers the organization of an email domain, the most specific wildcard wins
+func (m *DomainMatcher) Match(domain string) *DomainMatch {
+	domain = normalizeDomain(domain)
+	result := &DomainMatch{Domain: domain}
+	if domain == "" {
+		return result
+	}
+
+	if _, ok := m.publicExact[domain]; ok {
+		result.Public = true
+		return result
+	}
+	if parent, ok := matchWildcard(domain, func(parent string) bool {
+		_, ok := m.publicWildcard[parent]
+		return ok
+	}); ok {
+		result.Public = true
+		result.Pattern = "*." + parent
+		return result
+	}
+
+	if org, ok := m.exact[domain]; ok {
+		result.OrgName = org
+		result.Pattern = domain
+		result.Confidence = ExactDomainConfidence
+		return result
+	}
+
+	if parent, ok := matchWildcard(domain, func(parent string) bool {
+		_, ok := m.wildcards[parent]
+		return ok
+	}); ok {
+		result.OrgName = m.wildcards[parent]
+		result.Pattern = "*." + parent
+		result.Confidence = WildcardDomainConfidence
+	}
+
+	return result
+}
+
+// Apply fills the identity domain and, when the identity has no organization, sets it from the email domain
+// along with OrgInferred and OrgConfidence. It returns the match used, or nil when the identity already has an organization or has no valid email.
+func (m *DomainMatcher) Apply(identity *AffIdentity) *DomainMatch {
+	if identity == nil {
+		return nil
+	}
+	if identity.Domain == "" {
+		identity.Domain = EmailDomain(identity.Email)
+	}
+	if identity.Domain == "" || (identity.OrgName != nil && *identity.OrgName != "" && *identity.OrgName != unknown) {
+		return nil
+	}
+
+	match := m.Match(identity.Domain)
+	if match.OrgName != "" {
+		orgName := match.OrgName
+		identity.OrgName = &orgName
+		identity.MultiOrgNames = []string{orgName}
+		identity.OrgInferred = true
+		identity.OrgConfidence = match.Confidence
+	}
+
+	return match
+}
+
+// matchWildcard returns the longest parent of domain for which has is true
+func matchWildcard(domain string, has func(parent string) bool) (string, bool) {
+	for i := strings.Index(domain, "."); i >= 0; {
+		parent := domain[i+1:]
+		if has(parent) {
+			return parent, true
+		}
+		next := strings.Index(parent, ".")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+
+	return "", false
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
@@ -0,0 +1,53 @@
+package affiliation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainMatcher(t *testing.T) {
+	matcher, err := LoadDomainMatcher(strings.NewReader(`{"domains":{"linuxfoundation.org":"Linux Foundation","*.ibm.com":"IBM","*.research.ibm.com":"IBM Research"}}`))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		domain     string
+		orgName    string
+		confidence float64
+		public     bool
+	}{
+		{"LinuxFoundation.org", "Linux Foundation", ExactDomainConfidence, false},
+		{"us.ibm.com", "IBM", WildcardDomainConfidence, false},
+		{"zurich.research.ibm.com", "IBM Research", WildcardDomainConfidence, false},
+		{"ibm.com", "", 0, false},
+		{"gmail.com", "", 0, true},
+		{"example.com", "", 0, false},
+	}
+	for _, test := range tests {
+		match := matcher.Match(test.domain)
+		assert.Equal(t, test.orgName, match.OrgName, test.domain)
+		assert.Equal(t, test.confidence, match.Confidence, test.domain)
+		assert.Equal(t, test.public, match.Public, test.domain)
+	}
+}
+
+func TestDomainMatcherApply(t *testing.T) {
+	matcher := NewDomainMatcher(map[string]string{"cncf.io": "CNCF"}, nil)
+
+	identity := &AffIdentity{Email: "jdoe@cncf.io", OrgName: &unknown}
+	match := matcher.Apply(identity)
+	assert.Equal(t, "cncf.io", identity.Domain)
+	assert.Equal(t, "CNCF", *identity.OrgName)
+	assert.Equal(t, ExactDomainConfidence, match.Confidence)
+	assert.True(t, identity.OrgInferred)
+	assert.Equal(t, ExactDomainConfidence, identity.OrgConfidence)
+
+	orgName := "Google"
+	enrolled := &AffIdentity{Email: "jdoe@cncf.io", OrgName: &orgName}
+	assert.Nil(t, matcher.Apply(enrolled))
+	assert.Equal(t, "Google", *enrolled.OrgName)
+	assert.False(t, enrolled.OrgInferred)
+
+	assert.Nil(t, matcher.Apply(&AffIdentity{Email: "not an email"}))
+}
@@ -117,4 +117,35 @@ type AffIdentity struct {
 	OrgName       *string  `json:"org_name"`
 	IsBot         *int64   `json:"is_bot"`
 	MultiOrgNames []string `json:"multi_org_names"`
+	// OrgInferred is set when OrgName comes from the email domain rather than an enrollment, with OrgConfidence
+	OrgInferred   bool    `json:"org_inferred,omitempty"`
+	OrgConfidence float64 `json:"org_confidence,omitempty"`
+}
+
+// EnrollmentUpdate contains the fields used to add or remove an enrollment
+type EnrollmentUpdate struct {
+	OrgName string
+	Start   *time.Time
+	End     *time.Time
+	Role    string
+	Merge   bool
+}
+
+// ProfileUpdate contains the profile fields to set, nil fields are left untouched
+type ProfileUpdate struct {
+	IsBot       *bool
+	Gender      *string
+	GenderAcc   *int64
+	ClearGender bool
+}
+
+// WriteResult describes a write operation sent (or planned in dry-run mode) to the affiliation service
+type WriteResult struct {
+	Operation  string            `json:"operation"`
+	Method     string            `json:"method"`
+	Endpoint   string            `json:"endpoint"`
+	Params     map[string]string `json:"params,omitempty"`
+	DryRun     bool              `json:"dry_run"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Changes    []string          `json:"changes,omitempty"`
 }
@@ -0,0 +1,27 @@
+package affiliation
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrIdentityNotFound is returned when no identity matches the lookup
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrUserNotFound is returned when no profile matches the username
+	ErrUserNotFound = errors.New("user not found")
+	// ErrEmptyResponse is returned when the affiliation service answers without the expected data
+	ErrEmptyResponse = errors.New("empty affiliation response")
+)
+
+// ResponseError is returned when the affiliation service answers with an unexpected status
+type ResponseError struct {
+	Operation  string
+	StatusCode int
+	Message    string
+}
+
+// Error ...
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %d: %s", e.Operation, e.StatusCode, e.Message)
+}
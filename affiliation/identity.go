@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	httpClient "github.com/LF-Engineering/dev-analytics-libraries/http"
 )
 
 var (
@@ -17,11 +19,22 @@ var (
 	emailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 )
 
+// DefaultProfileSources is the sources preference order used when merging profile identities
+var DefaultProfileSources = []string{"github", "gitlab", "gerrit", "git", "jira", "confluence", "slack", "groupsio", "rocketchat", "discourse", "bugzilla", "stackexchange"}
+
 // Affiliations interface
 type Affiliations interface {
 	AddIdentity(identity *Identity) bool
 }
 
+// Resolver answers identity, profile and enrollment questions, either from the affiliation service or from a local snapshot
+type Resolver interface {
+	GetIdentityByUser(key string, value string) (*AffIdentity, error)
+	GetProfileByUsername(username string, projectSlug string) (*AffIdentity, error)
+	GetProfileByUsernameFromSources(username string, projectSlug string, sources ...string) (*AffIdentity, error)
+	GetOrganizations(uuid, projectSlug string) *[]Enrollment
+}
+
 // HTTPClientProvider used in connecting to remote http server
 type HTTPClientProvider interface {
 	Request(url string, method string, header map[string]string, body []byte, params map[string]string) (statusCode int, resBody []byte, err error)
@@ -35,7 +48,7 @@ type ESClientProvider interface {
 	Get(index string, query map[string]interface{}, result interface{}) error
 }
 
-// SlackProvider ...
+// SlackProvider receives alerts, a slack.Provider or any alert.Alerter
 type SlackProvider interface {
 	SendText(text string) error
 }
@@ -53,6 +66,13 @@ type Affiliation struct {
 	esClientProvider    ESClientProvider
 	auth0ClientProvider Auth0ClientProvider
 	slackProvider       SlackProvider
+	// DryRun makes write operations report the planned changes without sending them
+	DryRun bool
+	// WriteRetries and WriteRetryDelay bound the retries of the write operations, the defaults when zero
+	WriteRetries    int
+	WriteRetryDelay time.Duration
+	// DomainMatcher, when set, infers the organization from the email domain of identities without enrollments
+	DomainMatcher *DomainMatcher
 }
 
 // NewAffiliationsClient consumes
@@ -75,18 +95,49 @@ func NewAffiliationsClient(affBaseURL string,
 	return aff, nil
 }
 
+// SetRateLimiter limits the requests of the client with limiter, which may be shared with other clients calling the same hosts.
+// It returns httpClient.ErrNotClientProvider when the http client cannot be limited.
+func (a *Affiliation) SetRateLimiter(limiter *httpClient.RateLimiter) error {
+	p, ok := a.httpClientProvider.(*httpClient.ClientProvider)
+	if !ok {
+		return fmt.Errorf("SetRateLimiter: %w", httpClient.ErrNotClientProvider)
+	}
+	p.SetRateLimiter(limiter)
+	return nil
+}
+
+// SetCache caches the responses of the client in cache, which may be shared with other clients.
+// It returns httpClient.ErrNotClientProvider when the http client cannot cache.
+func (a *Affiliation) SetCache(cache *httpClient.Cache) error {
+	p, ok := a.httpClientProvider.(*httpClient.ClientProvider)
+	if !ok {
+		return fmt.Errorf("SetCache: %w", httpClient.ErrNotClientProvider)
+	}
+	p.SetCache(cache)
+	return nil
+}
+
+// Use adds middlewares to the requests of the client, to log them or record their metrics for example.
+// It returns httpClient.ErrNotClientProvider when the http client has no middlewares.
+func (a *Affiliation) Use(middlewares ...httpClient.Middleware) error {
+	p, ok := a.httpClientProvider.(*httpClient.ClientProvider)
+	if !ok {
+		return fmt.Errorf("Use: %w", httpClient.ErrNotClientProvider)
+	}
+	p.Use(middlewares...)
+	return nil
+}
+
 // AddIdentity ...
 func (a *Affiliation) AddIdentity(identity *Identity) bool {
 	if identity == nil {
 		log.Println("AddIdentity: Identity is nil")
 		return false
 	}
-	token, err := a.auth0ClientProvider.GetToken()
+	headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
 	if err != nil {
 		log.Println(err)
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	queryParams := make(map[string]string, 0)
 	queryParams["name"] = identity.Name
@@ -128,12 +179,10 @@ func (a *Affiliation) GetIdentity(uuid string) *Identity {
 		log.Println("GetIdentity: uuid is empty")
 		return nil
 	}
-	token, err := a.auth0ClientProvider.GetToken()
+	headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
 	if err != nil {
 		log.Println(err)
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	endpoint := a.AffBaseURL + "/affiliation/get_identity/" + uuid
 
@@ -156,12 +205,10 @@ func (a *Affiliation) GetOrganizations(uuid, projectSlug string) *[]Enrollment {
 	if uuid == "" || projectSlug == "" {
 		return nil
 	}
-	token, err := a.auth0ClientProvider.GetToken()
+	headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
 	if err != nil {
 		log.Println(err)
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(projectSlug) + "/enrollments/" + uuid
 
@@ -201,12 +248,10 @@ func (a *Affiliation) GetProfile(uuid, projectSlug string) *ProfileResponse {
 	if uuid == "" || projectSlug == "" {
 		return nil
 	}
-	token, err := a.auth0ClientProvider.GetToken()
+	headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
 	if err != nil {
 		log.Println(err)
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(projectSlug) + "/get_profile/" + uuid
 
@@ -231,20 +276,17 @@ func (a *Affiliation) GetIdentityByUser(key string, value string) (*AffIdentity,
 		nilKeyOrValueErr := "GetIdentityByUser: key or value is null"
 		return nil, fmt.Errorf(nilKeyOrValueErr)
 	}
-	token, err := a.auth0ClientProvider.GetToken()
+	headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 	endpoint := a.AffBaseURL + "/affiliation/" + "identity/" + key + "/" + value
 	statusCode, res, err := a.httpClientProvider.Request(strings.TrimSpace(endpoint), "GET", headers, nil, nil)
 	switch statusCode {
 	case http.StatusBadRequest, http.StatusNotFound:
 		log.Println("GetIdentityByUser: Could not get the identity: l", err)
-		return nil, errors.New("identity not found")
+		return nil, ErrIdentityNotFound
 	case http.StatusOK:
 	default:
 		if err != nil {
@@ -268,12 +310,16 @@ func (a *Affiliation) GetIdentityByUser(key string, value string) (*AffIdentity,
 		return nil, err
 	}
 
+	if ident.UUID == nil || *ident.UUID == "" {
+		return nil, ErrEmptyResponse
+	}
+
 	profileEndpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(a.ProjectSlug) + "/get_profile/" + *ident.UUID
 	statusCode, profileRes, err := a.httpClientProvider.Request(strings.TrimSpace(profileEndpoint), "GET", headers, nil, nil)
 	switch statusCode {
 	case http.StatusBadRequest, http.StatusNotFound:
 		log.Println("GetIdentityByUser: Could not get the identity: ", err)
-		return nil, errors.New("identity not found")
+		return nil, ErrIdentityNotFound
 	case http.StatusOK:
 	default:
 		if err != nil {
@@ -295,60 +341,33 @@ func (a *Affiliation) GetIdentityByUser(key string, value string) (*AffIdentity,
 	if err != nil {
 		return nil, err
 	}
-	var identity AffIdentity
-	identity.UUID = ident.UUID
-	if ident.Name != nil {
-		identity.Name = *ident.Name
-	}
-
-	if ident.Username != nil {
-		identity.Username = *ident.Username
-	}
-
-	if ident.Email != nil {
-		identity.Email = *ident.Email
+	identity := identityFromProfile(&ident, &profile)
+	if a.DomainMatcher != nil {
+		a.DomainMatcher.Apply(identity)
 	}
 
-	identity.ID = &ident.ID
-
-	identity.IsBot = profile.Profile.IsBot
-	identity.Gender = profile.Profile.Gender
-	identity.GenderACC = profile.Profile.GenderAcc
-
-	if len(profile.Enrollments) > 1 {
-		identity.OrgName = &profile.Enrollments[0].Organization.Name
-		for _, org := range profile.Enrollments {
-			identity.MultiOrgNames = append(identity.MultiOrgNames, org.Organization.Name)
-		}
-	} else if len(profile.Enrollments) == 1 {
-		identity.OrgName = &profile.Enrollments[0].Organization.Name
-		identity.MultiOrgNames = append(identity.MultiOrgNames, profile.Enrollments[0].Organization.Name)
-	}
-
-	if profile.Profile.Name != nil {
-		identity.Name = *profile.Profile.Name
-	}
-
-	return &identity, nil
-
+	return identity, nil
 }
 
-// GetProfileByUsername ...
+// GetProfileByUsername returns the profile of username, identity fields are taken from its github identity first
 func (a *Affiliation) GetProfileByUsername(username string, projectSlug string) (*AffIdentity, error) {
-	if username == "" && projectSlug == "" {
+	return a.GetProfileByUsernameFromSources(username, projectSlug, "github")
+}
+
+// GetProfileByUsernameFromSources returns the profile of username, identity fields are merged from its identities
+// following the sources preference order (DefaultProfileSources when empty), then from identities of any other source
+func (a *Affiliation) GetProfileByUsernameFromSources(username string, projectSlug string, sources ...string) (*AffIdentity, error) {
+	if username == "" || projectSlug == "" {
 		nilKeyOrValueErr := "GetProfileByUsername: username or projectSlug is null"
 		log.Println(nilKeyOrValueErr)
-		return nil, fmt.Errorf(nilKeyOrValueErr)
+		return nil, errors.New(nilKeyOrValueErr)
 	}
 
-	token, err := a.auth0ClientProvider.GetToken()
+	headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(projectSlug) + "/get_profile_by_username/" + url.PathEscape(username)
 	statusCode, res, err := a.httpClientProvider.Request(strings.TrimSpace(endpoint), "GET", headers, nil, nil)
 	if err != nil {
@@ -356,8 +375,12 @@ func (a *Affiliation) GetProfileByUsername(username string, projectSlug string)
 		return nil, err
 	}
 
-	if statusCode != 200 {
-		return nil, errors.New("user not found")
+	switch statusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound:
+		return nil, ErrUserNotFound
+	default:
+		return nil, &ResponseError{Operation: "GetProfileByUsername", StatusCode: statusCode, Message: responseMessage(res)}
 	}
 
 	var response ProfileByUsernameResponse
@@ -366,35 +389,102 @@ func (a *Affiliation) GetProfileByUsername(username string, projectSlug string)
 		return nil, err
 	}
 
-	profile := response.Profile[0]
+	if len(response.Profile) == 0 || response.Profile[0] == nil {
+		return nil, ErrEmptyResponse
+	}
+
+	identity := profileIdentity(username, response.Profile[0], sources)
+	if a.DomainMatcher != nil {
+		a.DomainMatcher.Apply(identity)
+	}
+
+	return identity, nil
+}
+
+// identityFromProfile builds an AffIdentity from an identity and the full profile of its unique identity
+func identityFromProfile(ident *IdentityData, profile *UniqueIdentityFullProfile) *AffIdentity {
 	var identity AffIdentity
+	identity.UUID = ident.UUID
+	if ident.Name != nil {
+		identity.Name = *ident.Name
+	}
 
-	for _, value := range profile.Identities {
-		if value.Source == "github" {
-			profileIdentity := value
-			identity.UUID = profileIdentity.UUID
-			if profileIdentity.Email != nil {
-				identity.Email = *profileIdentity.Email
-			}
+	if ident.Username != nil {
+		identity.Username = *ident.Username
+	}
 
-			identity.ID = &profileIdentity.ID
+	if ident.Email != nil {
+		identity.Email = *ident.Email
+		identity.Domain = EmailDomain(identity.Email)
+	}
 
-			if profile.Profile != nil {
-				identity.IsBot = profile.Profile.IsBot
-				identity.Name = *profile.Profile.Name
-			} else if profileIdentity.Name != nil {
-				identity.Name = *profileIdentity.Name
-			} else {
-				identity.Name = unknown
-			}
+	identity.ID = &ident.ID
+
+	if profile.Profile != nil {
+		identity.IsBot = profile.Profile.IsBot
+		identity.Gender = profile.Profile.Gender
+		identity.GenderACC = profile.Profile.GenderAcc
+	}
+
+	if len(profile.Enrollments) > 0 {
+		orgName := enrollmentOrgName(profile.Enrollments[0])
+		identity.OrgName = &orgName
+		for _, org := range profile.Enrollments {
+			identity.MultiOrgNames = append(identity.MultiOrgNames, enrollmentOrgName(org))
 		}
 	}
 
-	if len(profile.Identities) == 0 {
-		identity.Name = unknown
-		identity.ID = &unknown
+	if profile.Profile != nil && profile.Profile.Name != nil {
+		identity.Name = *profile.Profile.Name
+	}
+
+	return &identity
+}
+
+// profileIdentity builds the AffIdentity of username from the full profile of its unique identity.
+// The profile name comes first, then identity fields are taken from the first identity having them, ordered by sources.
+func profileIdentity(username string, profile *UniqueIdentityFullProfile, sources []string) *AffIdentity {
+	var identity AffIdentity
+
+	if profile.Profile != nil {
+		identity.IsBot = profile.Profile.IsBot
+		identity.Gender = profile.Profile.Gender
+		identity.GenderACC = profile.Profile.GenderAcc
+		if profile.Profile.Name != nil && *profile.Profile.Name != "" {
+			identity.Name = *profile.Profile.Name
+		}
+	}
+
+	for _, value := range orderIdentities(profile.Identities, sources) {
+		if identity.UUID == nil && value.UUID != nil && *value.UUID != "" {
+			identity.UUID = value.UUID
+		}
+		if identity.ID == nil && value.ID != "" {
+			id := value.ID
+			identity.ID = &id
+		}
+		if identity.Email == "" && value.Email != nil && *value.Email != "" {
+			identity.Email = *value.Email
+			identity.Domain = EmailDomain(identity.Email)
+		}
+		if identity.Name == "" && value.Name != nil && *value.Name != "" {
+			identity.Name = *value.Name
+		}
+	}
+
+	if identity.UUID == nil && profile.UUID != "" {
+		uuid := profile.UUID
+		identity.UUID = &uuid
+	}
+	if identity.UUID == nil {
 		identity.UUID = &unknown
 	}
+	if identity.ID == nil {
+		identity.ID = &unknown
+	}
+	if identity.Name == "" {
+		identity.Name = unknown
+	}
 
 	identity.Username = username
 
@@ -404,33 +494,69 @@ func (a *Affiliation) GetProfileByUsername(username string, projectSlug string)
 	}
 
 	if len(profile.Enrollments) > 1 {
-		identity.OrgName = a.getUserOrg(profile.Enrollments)
+		identity.OrgName = getUserOrg(profile.Enrollments)
 		for _, org := range profile.Enrollments {
-			identity.MultiOrgNames = append(identity.MultiOrgNames, org.Organization.Name)
+			identity.MultiOrgNames = append(identity.MultiOrgNames, enrollmentOrgName(org))
 		}
 	} else if len(profile.Enrollments) == 1 {
-		identity.OrgName = &profile.Enrollments[0].Organization.Name
-		identity.MultiOrgNames = append(identity.MultiOrgNames, profile.Enrollments[0].Organization.Name)
+		orgName := enrollmentOrgName(profile.Enrollments[0])
+		identity.OrgName = &orgName
+		identity.MultiOrgNames = append(identity.MultiOrgNames, orgName)
 	}
 
-	return &identity, nil
+	return &identity
+}
+
+// orderIdentities sorts identities by the sources preference order, identities of other sources come last
+func orderIdentities(identities []*IdentityData, sources []string) []*IdentityData {
+	if len(sources) == 0 {
+		sources = DefaultProfileSources
+	}
+	ordered := make([]*IdentityData, 0, len(identities))
+	used := make([]bool, len(identities))
+	for _, source := range sources {
+		for i, value := range identities {
+			if !used[i] && value != nil && strings.EqualFold(value.Source, source) {
+				ordered = append(ordered, value)
+				used[i] = true
+			}
+		}
+	}
+	for i, value := range identities {
+		if !used[i] && value != nil {
+			ordered = append(ordered, value)
+		}
+	}
+
+	return ordered
+}
+
+func enrollmentOrgName(enrollment *Enrollments) string {
+	if enrollment == nil || enrollment.Organization == nil {
+		return ""
+	}
+
+	return enrollment.Organization.Name
 }
 
 // Get Most Recent Org Name where user has multiple enrollments
-func (a *Affiliation) getUserOrg(enrollments []*Enrollments) *string {
+func getUserOrg(enrollments []*Enrollments) *string {
 	var result string
 	var lowest, startTime int64
 	now := time.Now()
 
 	for _, enrollment := range enrollments {
+		if enrollment == nil {
+			continue
+		}
 		startTime = now.Unix() - enrollment.Start.Unix()
 
 		if lowest == 0 {
 			lowest = startTime
-			result = enrollment.Organization.Name
+			result = enrollmentOrgName(enrollment)
 		} else if startTime < lowest {
 			lowest = startTime
-			result = enrollment.Organization.Name
+			result = enrollmentOrgName(enrollment)
 		}
 	}
 
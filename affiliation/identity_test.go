@@ -0,0 +1,74 @@
+package affiliation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	httpClient "github.com/LF-Engineering/dev-analytics-libraries/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProfileByUsernameFromSources(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	endpoint := baseURL + "/affiliation/" + projectSlug + "/get_profile_by_username/jsmith"
+	httpClientProvider.On("Request", endpoint, "GET", headers, []byte(nil), map[string]string(nil)).Return(200, []byte(`{"profiles":[{
+		"uuid":"u1",
+		"profile":{"uuid":"u1","is_bot":0},
+		"identities":[
+			{"id":"i1","uuid":"u1","source":"jira","username":"jsmith","email":"john@jira.example.com","name":"John (jira)"},
+			{"id":"i2","uuid":"u1","source":"gerrit","username":"jsmith","name":"John Smith"}
+		],
+		"enrollments":[{"organization":{"name":"Example"}}]
+	}]}`), nil)
+
+	identity, err := aff.GetProfileByUsernameFromSources("jsmith", projectSlug, "gerrit", "jira")
+	assert.NoError(t, err)
+	assert.Equal(t, "i2", *identity.ID)
+	assert.Equal(t, "John Smith", identity.Name)
+	assert.Equal(t, "john@jira.example.com", identity.Email)
+	assert.Equal(t, "jira.example.com", identity.Domain)
+	assert.Equal(t, int64(0), *identity.IsBot)
+	assert.Equal(t, "Example", *identity.OrgName)
+
+	// no github identity: fields come from the other sources instead of being left empty
+	identity, err = aff.GetProfileByUsername("jsmith", projectSlug)
+	assert.NoError(t, err)
+	assert.Equal(t, "i1", *identity.ID)
+	assert.Equal(t, "u1", *identity.UUID)
+}
+
+func TestGetProfileByUsernameErrors(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	endpoint := baseURL + "/affiliation/" + projectSlug + "/get_profile_by_username/"
+	httpClientProvider.On("Request", endpoint+"empty", "GET", headers, []byte(nil), map[string]string(nil)).Return(200, []byte(`{"profiles":[]}`), nil)
+	httpClientProvider.On("Request", endpoint+"missing", "GET", headers, []byte(nil), map[string]string(nil)).Return(404, []byte(`{}`), nil)
+	httpClientProvider.On("Request", endpoint+"broken", "GET", headers, []byte(nil), map[string]string(nil)).Return(500, []byte(`{"Message":"boom"}`), nil)
+
+	_, err := aff.GetProfileByUsername("empty", projectSlug)
+	assert.Equal(t, ErrEmptyResponse, err)
+
+	_, err = aff.GetProfileByUsername("missing", projectSlug)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	_, err = aff.GetProfileByUsername("broken", projectSlug)
+	responseErr, ok := err.(*ResponseError)
+	assert.True(t, ok)
+	assert.Equal(t, 500, responseErr.StatusCode)
+	assert.Equal(t, "boom", responseErr.Message)
+}
+
+func TestAffiliationHTTPSettings(t *testing.T) {
+	client, _ := newTestAffiliation()
+	assert.True(t, errors.Is(client.SetRateLimiter(httpClient.NewRateLimiter(httpClient.HostLimit{})), httpClient.ErrNotClientProvider))
+	assert.True(t, errors.Is(client.SetCache(httpClient.NewCache(httpClient.NewMemoryCache(0))), httpClient.ErrNotClientProvider))
+	assert.True(t, errors.Is(client.Use(), httpClient.ErrNotClientProvider))
+
+	client, _ = NewAffiliationsClient(baseURL, projectSlug, httpClient.NewClientProvider(time.Second), nil, nil, nil)
+	assert.NoError(t, client.SetRateLimiter(httpClient.NewRateLimiter(httpClient.HostLimit{})))
+	assert.NoError(t, client.SetCache(httpClient.NewCache(httpClient.NewMemoryCache(0))))
+	assert.NoError(t, client.Use())
+}
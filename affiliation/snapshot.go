@@ -0,0 +1,321 @@
+package affiliation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SnapshotESProvider used in reading a snapshot from an ES index
+type SnapshotESProvider interface {
+	ReadWithScroll(index string, query map[string]interface{}, result interface{}, scrollID string) error
+}
+
+// SnapshotProfile is a unique identity as exported in a snapshot: its profile, identities and enrollments
+type SnapshotProfile struct {
+	UUID        string                `json:"uuid"`
+	Profile     *Profile              `json:"profile,omitempty"`
+	Identities  []*IdentityData       `json:"identities"`
+	Enrollments []*SnapshotEnrollment `json:"enrollments"`
+}
+
+// SnapshotEnrollment is an enrollment as exported in a snapshot, enrollments without project slug apply to all projects
+type SnapshotEnrollment struct {
+	Enrollment
+	ProjectSlug string `json:"project_slug,omitempty"`
+}
+
+// Snapshot resolves identities, profiles and enrollments from an in memory copy of the affiliation data
+type Snapshot struct {
+	ProjectSlug string
+	// DomainMatcher, when set, infers the organization from the email domain of identities without enrollments
+	DomainMatcher *DomainMatcher
+	profiles      map[string]*SnapshotProfile
+	identities    map[string]map[string][]*IdentityData
+}
+
+// snapshotScrollSize is the number of profiles read per scroll page when loading from ES
+const snapshotScrollSize = 1000
+
+// NewSnapshot builds a snapshot from a list of profiles, projectSlug is used when resolving identities by user
+func NewSnapshot(projectSlug string, profiles []*SnapshotProfile) *Snapshot {
+	s := &Snapshot{
+		ProjectSlug: projectSlug,
+		profiles:    make(map[string]*SnapshotProfile, len(profiles)),
+		identities:  make(map[string]map[string][]*IdentityData),
+	}
+	for _, profile := range profiles {
+		s.add(profile)
+	}
+
+	return s
+}
+
+// LoadSnapshot reads a snapshot from a JSON array or from newline delimited JSON profiles
+func LoadSnapshot(projectSlug string, r io.Reader) (*Snapshot, error) {
+	reader := bufio.NewReader(r)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return NewSnapshot(projectSlug, nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []*SnapshotProfile
+	if first == '[' {
+		if err := json.NewDecoder(reader).Decode(&profiles); err != nil {
+			return nil, fmt.Errorf("LoadSnapshot: could not decode profiles: %s", err)
+		}
+		return NewSnapshot(projectSlug, profiles), nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var profile SnapshotProfile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return nil, fmt.Errorf("LoadSnapshot: could not decode profile at line %d: %s", line, err)
+		}
+		profiles = append(profiles, &profile)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewSnapshot(projectSlug, profiles), nil
+}
+
+// LoadSnapshotFile reads a snapshot from a JSON or NDJSON export file
+func LoadSnapshotFile(projectSlug string, path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadSnapshot(projectSlug, f)
+}
+
+// LoadSnapshotFromES reads a snapshot from an ES index where each document is a profile
+func LoadSnapshotFromES(projectSlug string, esClient SnapshotESProvider, index string) (*Snapshot, error) {
+	query := map[string]interface{}{
+		"size": snapshotScrollSize,
+		"query": map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		},
+	}
+
+	var profiles []*SnapshotProfile
+	scrollID := ""
+	for {
+		var page struct {
+			ScrollID string `json:"_scroll_id"`
+			Hits     struct {
+				Hits []struct {
+					Source SnapshotProfile `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if err := esClient.ReadWithScroll(index, query, &page, scrollID); err != nil {
+			return nil, err
+		}
+		if len(page.Hits.Hits) == 0 {
+			break
+		}
+		for i := range page.Hits.Hits {
+			profiles = append(profiles, &page.Hits.Hits[i].Source)
+		}
+		scrollID = page.ScrollID
+	}
+
+	return NewSnapshot(projectSlug, profiles), nil
+}
+
+// Len returns the number of unique identities in the snapshot
+func (s *Snapshot) Len() int {
+	return len(s.profiles)
+}
+
+// GetIdentityByUser returns the identity having key (id, uuid, name, email, username or source) equal to value
+func (s *Snapshot) GetIdentityByUser(key string, value string) (*AffIdentity, error) {
+	if key == "" || value == "" {
+		return nil, errors.New("GetIdentityByUser: key or value is null")
+	}
+
+	matches := s.identities[key][identityKeyValue(key, value)]
+	if len(matches) == 0 {
+		return nil, ErrIdentityNotFound
+	}
+	ident := matches[0]
+	profile, ok := s.profiles[*ident.UUID]
+	if !ok {
+		return nil, ErrIdentityNotFound
+	}
+
+	identity := identityFromProfile(ident, s.fullProfile(profile, s.ProjectSlug))
+	if s.DomainMatcher != nil {
+		s.DomainMatcher.Apply(identity)
+	}
+
+	return identity, nil
+}
+
+// GetProfileByUsername returns the profile of the unique identity having an identity with the given username
+func (s *Snapshot) GetProfileByUsername(username string, projectSlug string) (*AffIdentity, error) {
+	return s.GetProfileByUsernameFromSources(username, projectSlug, "github")
+}
+
+// GetProfileByUsernameFromSources returns the profile of the unique identity having an identity with the given username,
+// identity fields are merged following the sources preference order
+func (s *Snapshot) GetProfileByUsernameFromSources(username string, projectSlug string, sources ...string) (*AffIdentity, error) {
+	if username == "" || projectSlug == "" {
+		return nil, errors.New("GetProfileByUsername: username or projectSlug is null")
+	}
+
+	matches := s.identities["username"][identityKeyValue("username", username)]
+	if len(matches) == 0 {
+		return nil, ErrUserNotFound
+	}
+	profile, ok := s.profiles[*matches[0].UUID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	identity := profileIdentity(username, s.fullProfile(profile, projectSlug), sources)
+	if s.DomainMatcher != nil {
+		s.DomainMatcher.Apply(identity)
+	}
+
+	return identity, nil
+}
+
+// GetOrganizations returns the enrollments of the unique identity uuid in the project projectSlug
+func (s *Snapshot) GetOrganizations(uuid, projectSlug string) *[]Enrollment {
+	if uuid == "" || projectSlug == "" {
+		return nil
+	}
+	profile, ok := s.profiles[uuid]
+	if !ok {
+		return nil
+	}
+
+	enrollments := make([]Enrollment, 0)
+	for _, enrollment := range projectEnrollments(profile, projectSlug) {
+		enrollments = append(enrollments, enrollment.Enrollment)
+	}
+
+	return &enrollments
+}
+
+func (s *Snapshot) add(profile *SnapshotProfile) {
+	if profile == nil || profile.UUID == "" {
+		return
+	}
+	s.profiles[profile.UUID] = profile
+
+	for _, ident := range profile.Identities {
+		if ident == nil {
+			continue
+		}
+		if ident.UUID == nil || *ident.UUID == "" {
+			uuid := profile.UUID
+			ident.UUID = &uuid
+		}
+		s.index("id", ident.ID, ident)
+		s.index("uuid", *ident.UUID, ident)
+		s.index("source", ident.Source, ident)
+		if ident.Name != nil {
+			s.index("name", *ident.Name, ident)
+		}
+		if ident.Email != nil {
+			s.index("email", *ident.Email, ident)
+		}
+		if ident.Username != nil {
+			s.index("username", *ident.Username, ident)
+		}
+	}
+}
+
+func (s *Snapshot) index(key, value string, ident *IdentityData) {
+	if value == "" {
+		return
+	}
+	if _, ok := s.identities[key]; !ok {
+		s.identities[key] = make(map[string][]*IdentityData)
+	}
+	value = identityKeyValue(key, value)
+	s.identities[key][value] = append(s.identities[key][value], ident)
+}
+
+// fullProfile converts a snapshot profile to the shape returned by the affiliation service for a project
+func (s *Snapshot) fullProfile(profile *SnapshotProfile, projectSlug string) *UniqueIdentityFullProfile {
+	full := &UniqueIdentityFullProfile{
+		UUID:       profile.UUID,
+		Profile:    profile.Profile,
+		Identities: profile.Identities,
+	}
+	for _, enrollment := range projectEnrollments(profile, projectSlug) {
+		full.Enrollments = append(full.Enrollments, &Enrollments{
+			Organization: &Organization{Name: enrollment.Organization.Name},
+			End:          enrollment.End,
+			ID:           enrollment.ID,
+			Start:        enrollment.Start,
+		})
+	}
+
+	return full
+}
+
+// projectEnrollments returns the enrollments of a project, falling back to the global ones when the project has none
+func projectEnrollments(profile *SnapshotProfile, projectSlug string) []*SnapshotEnrollment {
+	var project, global []*SnapshotEnrollment
+	for _, enrollment := range profile.Enrollments {
+		if enrollment == nil {
+			continue
+		}
+		switch enrollment.ProjectSlug {
+		case projectSlug:
+			project = append(project, enrollment)
+		case "":
+			global = append(global, enrollment)
+		}
+	}
+	if len(project) > 0 {
+		return project
+	}
+
+	return global
+}
+
+// identityKeyValue normalizes values of case insensitive keys
+func identityKeyValue(key, value string) string {
+	if key == "email" {
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+
+	return value
+}
+
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return b, r.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package affiliation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// both the live client and the snapshot answer as a Resolver
+var _ Resolver = (*Affiliation)(nil)
+
+const snapshotNDJSON = `
+{"uuid":"u1","profile":{"uuid":"u1","name":"John Smith","is_bot":0},"identities":[{"id":"i1","source":"github","username":"jsmith","email":"JSmith@example.com","name":"john"},{"id":"i2","source":"gerrit","username":"john.smith"}],"enrollments":[{"organization":{"id":1,"name":"Global Org"},"start":"1900-01-01T00:00:00Z","end":"2100-01-01T00:00:00Z"},{"organization":{"id":2,"name":"Project Org"},"project_slug":"lfn","start":"1900-01-01T00:00:00Z","end":"2100-01-01T00:00:00Z"}]}
+
+{"uuid":"u2","identities":[{"id":"i3","source":"git","email":"bot@example.com"}]}
+`
+
+func TestLoadSnapshot(t *testing.T) {
+	snapshot, err := LoadSnapshot("lfn", strings.NewReader(snapshotNDJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, snapshot.Len())
+
+	array, err := LoadSnapshot("lfn", strings.NewReader("["+strings.Replace(strings.TrimSpace(snapshotNDJSON), "\n\n", ",", 1)+"]"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, array.Len())
+
+	_, err = LoadSnapshot("lfn", strings.NewReader("{\"uuid\":"))
+	assert.Error(t, err)
+}
+
+func TestSnapshotResolver(t *testing.T) {
+	var resolver Resolver
+	resolver, err := LoadSnapshot("lfn", strings.NewReader(snapshotNDJSON))
+	assert.NoError(t, err)
+
+	identity, err := resolver.GetIdentityByUser("email", "jsmith@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", *identity.UUID)
+	assert.Equal(t, "John Smith", identity.Name)
+	assert.Equal(t, "Project Org", *identity.OrgName)
+
+	_, err = resolver.GetIdentityByUser("email", "nobody@example.com")
+	assert.EqualError(t, err, "identity not found")
+
+	profile, err := resolver.GetProfileByUsername("jsmith", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, "i1", *profile.ID)
+	assert.Equal(t, "Global Org", *profile.OrgName)
+	assert.Equal(t, []string{"Global Org"}, profile.MultiOrgNames)
+
+	enrollments := resolver.GetOrganizations("u1", "lfn")
+	assert.Len(t, *enrollments, 1)
+	assert.Equal(t, "Project Org", (*enrollments)[0].Organization.Name)
+	assert.Empty(t, *resolver.GetOrganizations("u2", "lfn"))
+	assert.Nil(t, resolver.GetOrganizations("u3", "lfn"))
+}
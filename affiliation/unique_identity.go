@@ -0,0 +1,298 @@
+package affiliation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/auth0"
+	httpClient "github.com/LF-Engineering/dev-analytics-libraries/http"
+)
+
+const (
+	// DefaultWriteRetries is the max number of attempts of a write operation
+	DefaultWriteRetries = 3
+	// DefaultWriteRetryDelay is the wait before the second attempt of a failed write operation, doubled at each attempt
+	DefaultWriteRetryDelay = time.Second
+)
+
+// TokenRefresher is implemented by the auth0 clients able to renew a token, e.g. auth0.ClientProvider
+type TokenRefresher interface {
+	RefreshToken() (auth0.RefreshResult, error)
+}
+
+// MergeUniqueIdentities merges fromUUID unique identity into toUUID, all identities and enrollments of fromUUID are moved to toUUID
+func (a *Affiliation) MergeUniqueIdentities(fromUUID, toUUID string) (*WriteResult, error) {
+	if fromUUID == "" || toUUID == "" {
+		return nil, errors.New("MergeUniqueIdentities: fromUUID or toUUID is empty")
+	}
+	if fromUUID == toUUID {
+		return nil, errors.New("MergeUniqueIdentities: can not merge a unique identity into itself")
+	}
+
+	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(a.ProjectSlug) + "/merge_unique_identities/" + url.PathEscape(fromUUID) + "/" + url.PathEscape(toUUID)
+	params := map[string]string{"archive": "true"}
+	result := &WriteResult{Operation: "MergeUniqueIdentities", Method: "PUT", Endpoint: endpoint, Params: params}
+
+	if a.DryRun {
+		result.Changes = append(result.Changes, fmt.Sprintf("unique identity %s will be merged into %s", fromUUID, toUUID))
+		if profile := a.GetProfile(fromUUID, a.ProjectSlug); profile != nil {
+			for _, identity := range profile.Identities {
+				result.Changes = append(result.Changes, fmt.Sprintf("identity %s (%s) will move from %s to %s", identity.ID, identity.Source, fromUUID, toUUID))
+			}
+			for _, enrollment := range profile.Enrollments {
+				result.Changes = append(result.Changes, fmt.Sprintf("enrollment %s will move from %s to %s", enrollment.Organization.Name, fromUUID, toUUID))
+			}
+		}
+	}
+
+	return a.write(result)
+}
+
+// MoveIdentity moves the identity identityID to the unique identity toUUID
+func (a *Affiliation) MoveIdentity(identityID, toUUID string) (*WriteResult, error) {
+	if identityID == "" || toUUID == "" {
+		return nil, errors.New("MoveIdentity: identityID or toUUID is empty")
+	}
+
+	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(a.ProjectSlug) + "/move_identity/" + url.PathEscape(identityID) + "/" + url.PathEscape(toUUID)
+	params := map[string]string{"archive": "true"}
+	result := &WriteResult{Operation: "MoveIdentity", Method: "PUT", Endpoint: endpoint, Params: params}
+
+	if a.DryRun {
+		result.Changes = append(result.Changes, fmt.Sprintf("identity %s will move to %s", identityID, toUUID))
+		// the identities are looked up by uuid, only the target can be checked, unless it is created by the move
+		if toUUID != identityID {
+			if identity := a.GetIdentity(toUUID); identity == nil || identity.UUID == "" {
+				result.Changes = append(result.Changes, fmt.Sprintf("unique identity %s was not found", toUUID))
+			}
+		}
+	}
+
+	return a.write(result)
+}
+
+// UnmergeIdentity detaches the identity identityID from its unique identity into a new unique identity having the identity id as uuid
+func (a *Affiliation) UnmergeIdentity(identityID string) (*WriteResult, error) {
+	if identityID == "" {
+		return nil, errors.New("UnmergeIdentity: identityID is empty")
+	}
+
+	result, err := a.MoveIdentity(identityID, identityID)
+	if result != nil {
+		result.Operation = "UnmergeIdentity"
+	}
+
+	return result, err
+}
+
+// AddEnrollment enrolls the unique identity uuid into an organization
+func (a *Affiliation) AddEnrollment(uuid string, enrollment *EnrollmentUpdate) (*WriteResult, error) {
+	if uuid == "" || enrollment == nil || enrollment.OrgName == "" {
+		return nil, errors.New("AddEnrollment: uuid or organization name is empty")
+	}
+
+	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(a.ProjectSlug) + "/add_enrollment/" + url.PathEscape(uuid) + "/" + url.PathEscape(enrollment.OrgName)
+	params := enrollmentParams(enrollment)
+	if enrollment.Merge {
+		params["merge"] = "true"
+	}
+	result := &WriteResult{Operation: "AddEnrollment", Method: "POST", Endpoint: endpoint, Params: params}
+
+	if a.DryRun {
+		result.Changes = append(result.Changes, fmt.Sprintf("unique identity %s will be enrolled in %s %s", uuid, enrollment.OrgName, periodString(enrollment)))
+	}
+
+	return a.write(result)
+}
+
+// DeleteEnrollments removes the enrollments of the unique identity uuid in an organization, only those within the given period when start or end are set
+func (a *Affiliation) DeleteEnrollments(uuid string, enrollment *EnrollmentUpdate) (*WriteResult, error) {
+	if uuid == "" || enrollment == nil || enrollment.OrgName == "" {
+		return nil, errors.New("DeleteEnrollments: uuid or organization name is empty")
+	}
+
+	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(a.ProjectSlug) + "/delete_enrollments/" + url.PathEscape(uuid) + "/" + url.PathEscape(enrollment.OrgName)
+	result := &WriteResult{Operation: "DeleteEnrollments", Method: "DELETE", Endpoint: endpoint, Params: enrollmentParams(enrollment)}
+
+	if a.DryRun {
+		result.Changes = append(result.Changes, fmt.Sprintf("enrollments of unique identity %s in %s %s will be deleted", uuid, enrollment.OrgName, periodString(enrollment)))
+	}
+
+	return a.write(result)
+}
+
+// UpdateProfile sets or clears the is_bot and gender fields of the unique identity uuid profile
+func (a *Affiliation) UpdateProfile(uuid string, update *ProfileUpdate) (*WriteResult, error) {
+	if uuid == "" || update == nil {
+		return nil, errors.New("UpdateProfile: uuid or update is empty")
+	}
+
+	params := make(map[string]string)
+	if update.IsBot != nil {
+		params["is_bot"] = strconv.FormatBool(*update.IsBot)
+	}
+	if update.ClearGender {
+		params["gender"] = ""
+		params["gender_acc"] = ""
+	} else {
+		if update.Gender != nil {
+			params["gender"] = *update.Gender
+		}
+		if update.GenderAcc != nil {
+			params["gender_acc"] = strconv.FormatInt(*update.GenderAcc, 10)
+		}
+	}
+	if len(params) == 0 {
+		return nil, errors.New("UpdateProfile: nothing to update")
+	}
+
+	endpoint := a.AffBaseURL + "/affiliation/" + url.PathEscape(a.ProjectSlug) + "/edit_profile/" + url.PathEscape(uuid)
+	result := &WriteResult{Operation: "UpdateProfile", Method: "PUT", Endpoint: endpoint, Params: params}
+
+	if a.DryRun {
+		var current Profile
+		if profile := a.GetProfile(uuid, a.ProjectSlug); profile != nil {
+			current = profile.Profile
+		}
+		if update.IsBot != nil {
+			result.Changes = append(result.Changes, fmt.Sprintf("is_bot of %s will change from %s to %s", uuid, int64PtrString(current.IsBot), params["is_bot"]))
+		}
+		if _, ok := params["gender"]; ok {
+			result.Changes = append(result.Changes, fmt.Sprintf("gender of %s will change from %s to %q", uuid, stringPtrString(current.Gender), params["gender"]))
+		}
+		if _, ok := params["gender_acc"]; ok {
+			result.Changes = append(result.Changes, fmt.Sprintf("gender_acc of %s will change from %s to %q", uuid, int64PtrString(current.GenderAcc), params["gender_acc"]))
+		}
+	}
+
+	return a.write(result)
+}
+
+// SetIsBot sets the is_bot flag of the unique identity uuid profile
+func (a *Affiliation) SetIsBot(uuid string, isBot bool) (*WriteResult, error) {
+	return a.UpdateProfile(uuid, &ProfileUpdate{IsBot: &isBot})
+}
+
+// SetGender sets the gender and the gender accuracy of the unique identity uuid profile
+func (a *Affiliation) SetGender(uuid string, gender string, genderAcc int64) (*WriteResult, error) {
+	return a.UpdateProfile(uuid, &ProfileUpdate{Gender: &gender, GenderAcc: &genderAcc})
+}
+
+// ClearGender removes the gender and the gender accuracy from the unique identity uuid profile
+func (a *Affiliation) ClearGender(uuid string) (*WriteResult, error) {
+	return a.UpdateProfile(uuid, &ProfileUpdate{ClearGender: true})
+}
+
+// write sends a write operation to the affiliation service, retrying on server and transport errors.
+// A 401 refreshes the token once, when the auth0 client supports it. In dry-run mode the operation is returned as is without being sent.
+func (a *Affiliation) write(result *WriteResult) (*WriteResult, error) {
+	if a.DryRun {
+		result.DryRun = true
+		log.Printf("%s: dry run, %s %s %v", result.Operation, result.Method, result.Endpoint, result.Params)
+		return result, nil
+	}
+
+	retries, delay := a.WriteRetries, a.WriteRetryDelay
+	if retries <= 0 {
+		retries = DefaultWriteRetries
+	}
+	if delay <= 0 {
+		delay = DefaultWriteRetryDelay
+	}
+
+	var lastErr error
+	refreshed := false
+	for attempt := 1; attempt <= retries; attempt++ {
+		headers, err := httpClient.BearerHeader(a.auth0ClientProvider)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		statusCode, res, err := a.httpClientProvider.Request(strings.TrimSpace(result.Endpoint), result.Method, headers, nil, result.Params)
+		result.StatusCode = statusCode
+		switch {
+		case err != nil:
+			log.Printf("%s: request failed: %s", result.Operation, err)
+			lastErr = err
+		case statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices:
+			return result, nil
+		case statusCode == http.StatusUnauthorized && !refreshed:
+			refreshed = true
+			if refresher, ok := a.auth0ClientProvider.(TokenRefresher); ok {
+				if _, err := refresher.RefreshToken(); err != nil {
+					log.Printf("%s: could not refresh the token: %s", result.Operation, err)
+				}
+			}
+			// the attempt with the refreshed token is not counted as a retry
+			attempt--
+			continue
+		case statusCode >= http.StatusInternalServerError:
+			log.Printf("%s: unexpected status %d: %s", result.Operation, statusCode, responseMessage(res))
+			lastErr = fmt.Errorf("%s: unexpected status %d", result.Operation, statusCode)
+		default:
+			return result, fmt.Errorf("%s: request rejected with status %d: %s", result.Operation, statusCode, responseMessage(res))
+		}
+
+		if attempt < retries {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return result, lastErr
+}
+
+func enrollmentParams(enrollment *EnrollmentUpdate) map[string]string {
+	params := make(map[string]string)
+	if enrollment.Start != nil {
+		params["start"] = enrollment.Start.UTC().Format(time.RFC3339)
+	}
+	if enrollment.End != nil {
+		params["end"] = enrollment.End.UTC().Format(time.RFC3339)
+	}
+	if enrollment.Role != "" {
+		params["role"] = enrollment.Role
+	}
+	return params
+}
+
+func periodString(enrollment *EnrollmentUpdate) string {
+	start, end := "-", "-"
+	if enrollment.Start != nil {
+		start = enrollment.Start.UTC().Format("2006-01-02")
+	}
+	if enrollment.End != nil {
+		end = enrollment.End.UTC().Format("2006-01-02")
+	}
+	return fmt.Sprintf("[%s, %s]", start, end)
+}
+
+func responseMessage(res []byte) string {
+	var errMsg AffiliationsResponse
+	if err := json.Unmarshal(res, &errMsg); err == nil && errMsg.Message != "" {
+		return errMsg.Message
+	}
+	return string(res)
+}
+
+func int64PtrString(v *int64) string {
+	if v == nil {
+		return "null"
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func stringPtrString(v *string) string {
+	if v == nil {
+		return "null"
+	}
+	return strconv.Quote(*v)
+}
@@ -0,0 +1,158 @@
+package affiliation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/affiliation/mocks"
+	"github.com/LF-Engineering/dev-analytics-libraries/auth0"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	token       = "eyJhbGciOiJSUzI1NiIsInR5cCI6IkpXVCIsImtpZCI"
+	projectSlug = "lfn"
+	baseURL     = "AFFILIATION_SERVICE_ENDPOINT"
+)
+
+func newTestAffiliation() (*Affiliation, *mocks.HTTPClientProvider) {
+	httpClientProvider := &mocks.HTTPClientProvider{}
+	auth0ClientProvider := &mocks.Auth0ClientProvider{}
+	auth0ClientProvider.On("GetToken").Return(token, nil)
+	aff, _ := NewAffiliationsClient(baseURL, projectSlug, httpClientProvider, &mocks.ESClientProvider{}, auth0ClientProvider, &mocks.SlackProvider{})
+	return aff, httpClientProvider
+}
+
+func TestMergeUniqueIdentities(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	endpoint := baseURL + "/affiliation/" + projectSlug + "/merge_unique_identities/uuid1/uuid2"
+	httpClientProvider.On("Request", endpoint, "PUT", headers, []byte(nil), map[string]string{"archive": "true"}).Return(200, []byte(`{}`), nil)
+
+	result, err := aff.MergeUniqueIdentities("uuid1", "uuid2")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.False(t, result.DryRun)
+	httpClientProvider.AssertExpectations(t)
+}
+
+func TestMergeUniqueIdentitiesRejected(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	endpoint := baseURL + "/affiliation/" + projectSlug + "/merge_unique_identities/uuid1/uuid3"
+	httpClientProvider.On("Request", endpoint, "PUT", headers, []byte(nil), map[string]string{"archive": "true"}).Return(404, []byte(`{"Message":"uuid3 not found"}`), nil)
+
+	_, err := aff.MergeUniqueIdentities("uuid1", "uuid3")
+	assert.EqualError(t, err, "MergeUniqueIdentities: request rejected with status 404: uuid3 not found")
+
+	_, err = aff.MergeUniqueIdentities("uuid1", "uuid1")
+	assert.Error(t, err)
+}
+
+func TestUpdateProfileDryRun(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	aff.DryRun = true
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	profileEndpoint := baseURL + "/affiliation/" + projectSlug + "/get_profile/uuid1"
+	httpClientProvider.On("Request", profileEndpoint, "GET", headers, []byte(nil), map[string]string(nil)).Return(200, []byte(`{"uuid":"uuid1","profile":{"uuid":"uuid1","is_bot":0,"gender":"male"}}`), nil)
+
+	isBot := true
+	result, err := aff.UpdateProfile("uuid1", &ProfileUpdate{IsBot: &isBot, ClearGender: true})
+	assert.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, "PUT", result.Method)
+	assert.Equal(t, map[string]string{"is_bot": "true", "gender": "", "gender_acc": ""}, result.Params)
+	assert.Equal(t, []string{
+		"is_bot of uuid1 will change from 0 to true",
+		`gender of uuid1 will change from "male" to ""`,
+		`gender_acc of uuid1 will change from null to ""`,
+	}, result.Changes)
+	httpClientProvider.AssertNotCalled(t, "Request", baseURL+"/affiliation/"+projectSlug+"/edit_profile/uuid1", "PUT", headers, []byte(nil), result.Params)
+}
+
+func TestMoveIdentityDryRun(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	aff.DryRun = true
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	httpClientProvider.On("Request", baseURL+"/affiliation/get_identity/uuid2", "GET", headers, []byte(nil), map[string]string(nil)).Return(200, []byte(`{"uuid":"uuid2"}`), nil)
+	httpClientProvider.On("Request", baseURL+"/affiliation/get_identity/uuid3", "GET", headers, []byte(nil), map[string]string(nil)).Return(404, []byte(`{}`), nil)
+
+	result, err := aff.MoveIdentity("id1", "uuid2")
+	assert.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"identity id1 will move to uuid2"}, result.Changes)
+
+	result, err = aff.MoveIdentity("id1", "uuid3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"identity id1 will move to uuid3", "unique identity uuid3 was not found"}, result.Changes)
+
+	// the unmerged identity becomes its own unique identity, nothing to look up
+	result, err = aff.UnmergeIdentity("id1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"identity id1 will move to id1"}, result.Changes)
+
+	httpClientProvider.AssertNumberOfCalls(t, "Request", 2)
+	httpClientProvider.AssertNotCalled(t, "Request", baseURL+"/affiliation/get_identity/id1", "GET", headers, []byte(nil), map[string]string(nil))
+}
+
+type refreshingAuth0Client struct {
+	tokens    []string
+	refreshes int
+}
+
+func (c *refreshingAuth0Client) GetToken() (string, error) {
+	return c.tokens[c.refreshes], nil
+}
+
+func (c *refreshingAuth0Client) RefreshToken() (auth0.RefreshResult, error) {
+	c.refreshes++
+	return auth0.RefreshSuccessful, nil
+}
+
+func TestWriteRetries(t *testing.T) {
+	aff, httpClientProvider := newTestAffiliation()
+	aff.WriteRetryDelay = time.Millisecond
+	headers := map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+	params := map[string]string{"archive": "true"}
+	endpoint := baseURL + "/affiliation/" + projectSlug + "/move_identity/"
+
+	// the server errors are retried
+	httpClientProvider.On("Request", endpoint+"id1/uuid1", "PUT", headers, []byte(nil), params).Return(503, []byte(`{}`), nil).Once()
+	httpClientProvider.On("Request", endpoint+"id1/uuid1", "PUT", headers, []byte(nil), params).Return(200, []byte(`{}`), nil).Once()
+	result, err := aff.MoveIdentity("id1", "uuid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+
+	// the client errors are not
+	httpClientProvider.On("Request", endpoint+"id2/uuid1", "PUT", headers, []byte(nil), params).Return(403, []byte(`{"Message":"forbidden"}`), nil).Once()
+	_, err = aff.MoveIdentity("id2", "uuid1")
+	assert.EqualError(t, err, "MoveIdentity: request rejected with status 403: forbidden")
+
+	httpClientProvider.On("Request", endpoint+"id3/uuid1", "PUT", headers, []byte(nil), params).Return(0, []byte(nil), errors.New("connection reset"))
+	_, err = aff.MoveIdentity("id3", "uuid1")
+	assert.EqualError(t, err, "connection reset")
+	httpClientProvider.AssertNumberOfCalls(t, "Request", 2+1+DefaultWriteRetries)
+}
+
+func TestWriteRefreshesTokenOnUnauthorized(t *testing.T) {
+	httpClientProvider := &mocks.HTTPClientProvider{}
+	auth0Client := &refreshingAuth0Client{tokens: []string{"expired", token, token}}
+	aff, _ := NewAffiliationsClient(baseURL, projectSlug, httpClientProvider, &mocks.ESClientProvider{}, auth0Client, &mocks.SlackProvider{})
+	params := map[string]string{"archive": "true"}
+	endpoint := baseURL + "/affiliation/" + projectSlug + "/move_identity/id1/uuid1"
+
+	httpClientProvider.On("Request", endpoint, "PUT", map[string]string{"Authorization": "Bearer expired"}, []byte(nil), params).Return(401, []byte(`{}`), nil)
+	httpClientProvider.On("Request", endpoint, "PUT", map[string]string{"Authorization": "Bearer " + token}, []byte(nil), params).Return(401, []byte(`{}`), nil).Once()
+	httpClientProvider.On("Request", endpoint, "PUT", map[string]string{"Authorization": "Bearer " + token}, []byte(nil), params).Return(200, []byte(`{}`), nil).Once()
+
+	// the token is refreshed once, the second 401 is rejected
+	_, err := aff.MoveIdentity("id1", "uuid1")
+	assert.EqualError(t, err, "MoveIdentity: request rejected with status 401: {}")
+	assert.Equal(t, 1, auth0Client.refreshes)
+
+	result, err := aff.MoveIdentity("id1", "uuid1")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+}
@@ -0,0 +1,308 @@
+package alert
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity of an alert
+type Severity int
+
+const (
+	// Info ...
+	Info Severity = iota
+	// Warning ...
+	Warning
+	// Error ...
+	Error
+	// Critical ...
+	Critical
+)
+
+// String ...
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	case Critical:
+		return "critical"
+	}
+
+	return "unknown"
+}
+
+// ErrFlushTimeout is returned when the queued alerts could not be sent in time
+var ErrFlushTimeout = errors.New("timeout flushing alerts")
+
+// Alert is what a Sink receives, Repeated counts the identical alerts suppressed since the last one sent
+type Alert struct {
+	Severity Severity
+	Message  string
+	Time     time.Time
+	Repeated int
+}
+
+// Sink delivers alerts, e.g. to slack or to the logs
+type Sink interface {
+	Send(alert Alert) error
+}
+
+// Alerter reports alerts without blocking the caller.
+// SendText lets an Alerter be used wherever a SlackProvider is expected, it reports an Error alert.
+type Alerter interface {
+	Alert(severity Severity, message string)
+	SendText(text string) error
+	Flush(timeout time.Duration) error
+}
+
+// TextSender is the SendText method of slack.Provider and of the SlackProvider interfaces
+type TextSender interface {
+	SendText(text string) error
+}
+
+// Config of a Dispatcher, zero values use the defaults
+type Config struct {
+	// MinSeverity drops the alerts below it
+	MinSeverity Severity
+	// DedupWindow suppresses identical alerts, 10 minutes by default
+	DedupWindow time.Duration
+	// Burst alerts may be sent at once, then one every Interval, 5 and 1 minute by default
+	Burst    int
+	Interval time.Duration
+	// QueueSize bounds the alerts waiting to be sent, 100 by default
+	QueueSize int
+}
+
+// Dispatcher is an Alerter deduplicating and rate limiting the alerts it sends to a sink from a single goroutine
+type Dispatcher struct {
+	sink   Sink
+	config Config
+	queue  chan queued
+	done   chan struct{}
+
+	mu        sync.Mutex
+	seen      map[string]*seenAlert
+	tokens    float64
+	refilled  time.Time
+	dropped   int
+	closed    bool
+	closeOnce sync.Once
+}
+
+// queued is an alert waiting to be sent, or a flush marker when flushed is set
+type queued struct {
+	alert   Alert
+	flushed chan struct{}
+}
+
+type seenAlert struct {
+	severity   Severity
+	message    string
+	sentAt     time.Time
+	suppressed int
+}
+
+// New creates a dispatcher sending to sink, call Close on shutdown to send the queued alerts
+func New(sink Sink, config Config) *Dispatcher {
+	if config.DedupWindow <= 0 {
+		config.DedupWindow = 10 * time.Minute
+	}
+	if config.Burst <= 0 {
+		config.Burst = 5
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	d := &Dispatcher{
+		sink:     sink,
+		config:   config,
+		queue:    make(chan queued, config.QueueSize),
+		done:     make(chan struct{}),
+		seen:     make(map[string]*seenAlert),
+		tokens:   float64(config.Burst),
+		refilled: time.Now(),
+	}
+	go d.run()
+
+	return d
+}
+
+// Wrap returns sender as an Alerter: itself when it already is one, a Dispatcher sending to slack otherwise.
+// It returns nil when sender is nil.
+func Wrap(sender TextSender) Alerter {
+	if sender == nil {
+		return nil
+	}
+	if alerter, ok := sender.(Alerter); ok {
+		return alerter
+	}
+
+	return New(NewSlackSink(sender), Config{})
+}
+
+// Alert queues the alert unless it is a duplicate, it is rate limited or the queue is full
+func (d *Dispatcher) Alert(severity Severity, message string) {
+	if severity < d.config.MinSeverity || strings.TrimSpace(message) == "" {
+		return
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+
+	key := severity.String() + "\x00" + message
+	seen, ok := d.seen[key]
+	if ok && now.Sub(seen.sentAt) < d.config.DedupWindow {
+		seen.suppressed++
+		return
+	}
+	// the suppressed count of this alert is reported with it rather than by expire
+	delete(d.seen, key)
+	d.expire(now)
+
+	alert := Alert{Severity: severity, Message: message, Time: now}
+	if ok {
+		alert.Repeated = seen.suppressed
+	}
+	if d.enqueue(alert, now) {
+		d.seen[key] = &seenAlert{severity: severity, message: message, sentAt: now}
+	} else if ok {
+		// the repeats are reported by the next send of the alert, this one included
+		seen.suppressed++
+		d.seen[key] = seen
+	}
+}
+
+// enqueue queues alert unless it is rate limited or the queue is full
+func (d *Dispatcher) enqueue(alert Alert, now time.Time) bool {
+	if !d.take(now) {
+		d.dropped++
+		return false
+	}
+
+	select {
+	case d.queue <- queued{alert: alert}:
+		return true
+	default:
+		d.dropped++
+		return false
+	}
+}
+
+// SendText reports an Error alert
+func (d *Dispatcher) SendText(text string) error {
+	d.Alert(Error, text)
+	return nil
+}
+
+// Flush waits until the alerts queued so far are sent, it returns at once when the dispatcher is closed
+func (d *Dispatcher) Flush(timeout time.Duration) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	flushed := make(chan struct{})
+	select {
+	case d.queue <- queued{flushed: flushed}:
+	case <-d.done:
+		return nil
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+
+	// a Close racing with the flush stops the run loop before it reaches the marker
+	select {
+	case <-flushed:
+		return nil
+	case <-d.done:
+		return nil
+	case <-timer.C:
+		return ErrFlushTimeout
+	}
+}
+
+// Close sends the queued alerts and stops the dispatcher, later alerts are ignored
+func (d *Dispatcher) Close(timeout time.Duration) error {
+	err := d.Flush(timeout)
+	d.closeOnce.Do(func() {
+		d.mu.Lock()
+		d.closed = true
+		if d.dropped > 0 {
+			log.Printf("alert: %d alerts dropped by the rate limit\n", d.dropped)
+		}
+		d.mu.Unlock()
+		close(d.done)
+	})
+
+	return err
+}
+
+func (d *Dispatcher) run() {
+	for {
+		select {
+		case q := <-d.queue:
+			d.send(q)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) send(q queued) {
+	if q.flushed != nil {
+		close(q.flushed)
+		return
+	}
+	if err := d.sink.Send(q.alert); err != nil {
+		log.Println("alert: could not send alert: ", err)
+	}
+}
+
+// take consumes a rate limit token, refilling one per Interval up to Burst
+func (d *Dispatcher) take(now time.Time) bool {
+	d.tokens += float64(now.Sub(d.refilled)) / float64(d.config.Interval)
+	if max := float64(d.config.Burst); d.tokens > max {
+		d.tokens = max
+	}
+	d.refilled = now
+	if d.tokens < 1 {
+		return false
+	}
+	d.tokens--
+
+	return true
+}
+
+// expire forgets the alerts sent before the dedup window, sending the count of their suppressed repeats first.
+// An alert whose count cannot be sent yet is kept for a later try.
+func (d *Dispatcher) expire(now time.Time) {
+	for key, seen := range d.seen {
+		if now.Sub(seen.sentAt) < d.config.DedupWindow {
+			continue
+		}
+		if seen.suppressed > 0 && !d.enqueue(Alert{Severity: seen.severity, Message: seen.message, Time: now, Repeated: seen.suppressed}, now) {
+			continue
+		}
+		delete(d.seen, key)
+	}
+}
@@ -0,0 +1,153 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingSink holds every alert until release is closed
+type blockingSink struct {
+	release chan struct{}
+	rec     Recorder
+}
+
+func (s *blockingSink) Send(alert Alert) error {
+	<-s.release
+	return s.rec.Send(alert)
+}
+
+func TestDispatcherDeduplicates(t *testing.T) {
+	rec := NewRecorder()
+	d := New(rec, Config{DedupWindow: 50 * time.Millisecond})
+	defer d.Close(time.Second)
+
+	for i := 0; i < 5; i++ {
+		d.Alert(Error, "es is down")
+	}
+	d.Alert(Warning, "es is down")
+	assert.NoError(t, d.Flush(time.Second))
+	assert.Equal(t, []string{"es is down", "es is down"}, rec.Messages())
+
+	time.Sleep(60 * time.Millisecond)
+	d.Alert(Error, "es is down")
+	assert.NoError(t, d.Flush(time.Second))
+	alerts := rec.Alerts()
+	assert.Len(t, alerts, 3)
+	assert.Equal(t, 4, alerts[2].Repeated)
+	assert.Equal(t, "[ERROR] es is down (repeated 4 times)", Format(alerts[2]))
+}
+
+func TestDispatcherRateLimitAndSeverity(t *testing.T) {
+	rec := NewRecorder()
+	d := New(rec, Config{MinSeverity: Warning, Burst: 2, Interval: time.Hour})
+	defer d.Close(time.Second)
+
+	d.Alert(Info, "ignored")
+	d.Alert(Warning, "first")
+	d.Alert(Error, "second")
+	d.Alert(Critical, "third")
+	assert.NoError(t, d.Flush(time.Second))
+	assert.Equal(t, []string{"first", "second"}, rec.Messages())
+}
+
+func TestDispatcherFlushTimeoutAndClose(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	d := New(sink, Config{})
+
+	assert.NoError(t, d.SendText("stuck"))
+	assert.Equal(t, ErrFlushTimeout, d.Flush(10*time.Millisecond))
+
+	close(sink.release)
+	assert.NoError(t, d.Close(time.Second))
+	assert.Equal(t, []string{"stuck"}, sink.rec.Messages())
+	assert.Equal(t, Error, sink.rec.Alerts()[0].Severity)
+
+	// alerts after Close are ignored
+	d.Alert(Critical, "late")
+	assert.NoError(t, d.Flush(time.Second))
+	assert.Len(t, sink.rec.Alerts(), 1)
+}
+
+type textSender struct {
+	texts []string
+	err   error
+}
+
+func (s *textSender) SendText(text string) error {
+	s.texts = append(s.texts, text)
+	return s.err
+}
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, Wrap(nil))
+
+	rec := NewRecorder()
+	assert.True(t, Wrap(rec) == Alerter(rec))
+
+	slack := &textSender{err: errors.New("slack is down")}
+	alerter := Wrap(slack)
+	alerter.Alert(Critical, "token is invalid")
+	assert.NoError(t, alerter.Flush(time.Second))
+	assert.Equal(t, []string{"[CRITICAL] token is invalid"}, slack.texts)
+}
+
+func TestDispatcherExpiresRepeatedAlerts(t *testing.T) {
+	rec := NewRecorder()
+	d := New(rec, Config{DedupWindow: 20 * time.Millisecond})
+	defer d.Close(time.Second)
+
+	d.Alert(Error, "es is down")
+	d.Alert(Error, "es is down")
+	d.Alert(Error, "es is down")
+	time.Sleep(30 * time.Millisecond)
+
+	// another alert expires the repeated one after reporting its repeats
+	d.Alert(Warning, "auth0 is slow")
+	assert.NoError(t, d.Flush(time.Second))
+	alerts := rec.Alerts()
+	assert.Len(t, alerts, 3)
+	assert.Equal(t, "[ERROR] es is down (repeated 2 times)", Format(alerts[1]))
+	assert.Equal(t, "auth0 is slow", alerts[2].Message)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	assert.Len(t, d.seen, 1)
+}
+
+func TestDispatcherKeepsRepeatsOfRateLimitedAlerts(t *testing.T) {
+	rec := NewRecorder()
+	d := New(rec, Config{DedupWindow: 10 * time.Millisecond, Burst: 1, Interval: time.Hour})
+	defer d.Close(time.Second)
+
+	d.Alert(Error, "es is down")
+	d.Alert(Error, "es is down")
+	time.Sleep(20 * time.Millisecond)
+	// rate limited, its repeats and itself are reported with the next send
+	d.Alert(Error, "es is down")
+
+	d.mu.Lock()
+	d.tokens = 1
+	d.mu.Unlock()
+	d.Alert(Error, "es is down")
+	assert.NoError(t, d.Flush(time.Second))
+	alerts := rec.Alerts()
+	assert.Len(t, alerts, 2)
+	assert.Equal(t, 2, alerts[1].Repeated)
+}
+
+func TestDispatcherFlushAfterClose(t *testing.T) {
+	d := New(NewRecorder(), Config{})
+	d.Alert(Error, "es is down")
+	assert.NoError(t, d.Close(time.Second))
+
+	// the run loop is stopped, the flush marker would never be reached
+	d.mu.Lock()
+	d.closed = false
+	d.mu.Unlock()
+	start := time.Now()
+	assert.NoError(t, d.Flush(time.Second))
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+}
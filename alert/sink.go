@@ -0,0 +1,103 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlackSink posts alerts with a slack.Provider
+type SlackSink struct {
+	slack TextSender
+}
+
+// NewSlackSink ...
+func NewSlackSink(slack TextSender) *SlackSink {
+	return &SlackSink{slack: slack}
+}
+
+// Send ...
+func (s *SlackSink) Send(alert Alert) error {
+	return s.slack.SendText(Format(alert))
+}
+
+// LogSink writes alerts to the standard logger
+type LogSink struct{}
+
+// Send ...
+func (LogSink) Send(alert Alert) error {
+	log.Println(Format(alert))
+	return nil
+}
+
+// Format renders an alert as "[SEVERITY] message (repeated n times)"
+func Format(alert Alert) string {
+	text := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Severity.String()), alert.Message)
+	if alert.Repeated > 0 {
+		text += fmt.Sprintf(" (repeated %d times)", alert.Repeated)
+	}
+
+	return text
+}
+
+// Recorder is an Alerter and a Sink keeping every alert in memory, for tests
+type Recorder struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+// NewRecorder ...
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Alert ...
+func (r *Recorder) Alert(severity Severity, message string) {
+	_ = r.Send(Alert{Severity: severity, Message: message, Time: time.Now()})
+}
+
+// SendText ...
+func (r *Recorder) SendText(text string) error {
+	r.Alert(Error, text)
+	return nil
+}
+
+// Send ...
+func (r *Recorder) Send(alert Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+// Flush ...
+func (r *Recorder) Flush(timeout time.Duration) error {
+	return nil
+}
+
+// Alerts returns the recorded alerts
+func (r *Recorder) Alerts() []Alert {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Alert(nil), r.alerts...)
+}
+
+// Messages returns the recorded alerts messages
+func (r *Recorder) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]string, 0, len(r.alerts))
+	for _, a := range r.alerts {
+		messages = append(messages, a.Message)
+	}
+	return messages
+}
+
+// Reset forgets the recorded alerts
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = nil
+}
@@ -0,0 +1,325 @@
+// Package auth0test provides a fake auth0 tenant for tests, serving RS256 tokens and their jwks over http
+package auth0test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// TokenPath is the client credentials endpoint
+	TokenPath = "/oauth/token"
+	// JwksPath is the jwks endpoint
+	JwksPath = "/oauth/.well-known/jwks.json"
+	// DiscoveryPath is the openid configuration endpoint
+	DiscoveryPath = "/.well-known/openid-configuration"
+	// DefaultExpiry of the issued tokens
+	DefaultExpiry = 24 * time.Hour
+)
+
+// Server is a fake auth0 tenant, its URL is the auth url of the auth0 clients
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	keys     []signingKey
+	expiry   time.Duration
+	claims   jwt.MapClaims
+	clients  map[string]string
+	failures map[string][]failure
+	requests map[string]int
+}
+
+type signingKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// failure is an injected error response
+type failure struct {
+	status int
+	body   string
+}
+
+// NewServer starts a server signing with one key, call Close when done
+func NewServer() *Server {
+	s := &Server{
+		expiry:   DefaultExpiry,
+		claims:   jwt.MapClaims{},
+		failures: make(map[string][]failure),
+		requests: make(map[string]int),
+	}
+	if _, err := s.RotateKey(); err != nil {
+		panic(fmt.Sprintf("auth0test: could not generate a signing key: %v", err))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(TokenPath, s.handleToken)
+	mux.HandleFunc(JwksPath, s.handleJwks)
+	mux.HandleFunc(DiscoveryPath, s.handleDiscovery)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Issuer is the iss claim of the issued tokens
+func (s *Server) Issuer() string {
+	return s.URL + "/"
+}
+
+// SetExpiry sets the lifetime of the tokens issued from now on
+func (s *Server) SetExpiry(expiry time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiry = expiry
+}
+
+// SetClaims adds claims to the tokens issued from now on, overriding the default ones
+func (s *Server) SetClaims(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = jwt.MapClaims{}
+	for k, v := range claims {
+		s.claims[k] = v
+	}
+}
+
+// SetClient only accepts token requests with this client id and secret, any client is accepted by default
+func (s *Server) SetClient(id, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients == nil {
+		s.clients = make(map[string]string)
+	}
+	s.clients[id] = secret
+}
+
+// RotateKey signs the tokens issued from now on with a new key, previous keys stay in the jwks until RetireKeys
+func (s *Server) RotateKey() (string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kid := fmt.Sprintf("key-%d", len(s.keys)+1)
+	s.keys = append(s.keys, signingKey{kid: kid, key: key})
+
+	return kid, nil
+}
+
+// RetireKeys removes every key but the current one from the jwks
+func (s *Server) RetireKeys() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = s.keys[len(s.keys)-1:]
+}
+
+// KeyID returns the kid of the current signing key
+func (s *Server) KeyID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[len(s.keys)-1].kid
+}
+
+// FailTokenRequests answers the next count token requests with status and body
+func (s *Server) FailTokenRequests(status, count int, body string) {
+	s.fail(TokenPath, status, count, body)
+}
+
+// FailJwksRequests answers the next count jwks requests with status and body
+func (s *Server) FailJwksRequests(status, count int, body string) {
+	s.fail(JwksPath, status, count, body)
+}
+
+// TokenRequests returns the number of token requests received
+func (s *Server) TokenRequests() int {
+	return s.count(TokenPath)
+}
+
+// JwksRequests returns the number of jwks requests received
+func (s *Server) JwksRequests() int {
+	return s.count(JwksPath)
+}
+
+// Issue signs a token with the current key. The default claims are iss, iat and exp, claims override them.
+func (s *Server) Issue(claims map[string]interface{}) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issue(claims)
+}
+
+func (s *Server) issue(claims map[string]interface{}) string {
+	now := time.Now()
+	all := jwt.MapClaims{
+		"iss": s.URL + "/",
+		"iat": now.Unix(),
+		"exp": now.Add(s.expiry).Unix(),
+	}
+	for k, v := range s.claims {
+		all[k] = v
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	current := s.keys[len(s.keys)-1]
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	token.Header["kid"] = current.kid
+	signed, err := token.SignedString(current.key)
+	if err != nil {
+		panic(fmt.Sprintf("auth0test: could not sign a token: %v", err))
+	}
+
+	return signed
+}
+
+func (s *Server) fail(path string, status, count int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.failures[path] = append(s.failures[path], failure{status: status, body: body})
+	}
+}
+
+func (s *Server) count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// injected counts the request and writes the next injected failure of path, if any
+func (s *Server) injected(w http.ResponseWriter, path string) bool {
+	s.requests[path]++
+	failures := s.failures[path]
+	if len(failures) == 0 {
+		return false
+	}
+	s.failures[path] = failures[1:]
+	w.WriteHeader(failures[0].status)
+	_, _ = w.Write([]byte(failures[0].body))
+
+	return true
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.injected(w, TokenPath) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method_not_allowed"})
+		return
+	}
+
+	params, err := tokenParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	if params["grant_type"] != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+	if s.clients != nil {
+		if secret, ok := s.clients[params["client_id"]]; !ok || secret != params["client_secret"] {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "access_denied", "error_description": "Unauthorized"})
+			return
+		}
+	}
+
+	claims := map[string]interface{}{
+		"sub": params["client_id"] + "@clients",
+		"azp": params["client_id"],
+		"gty": "client-credentials",
+	}
+	if params["audience"] != "" {
+		claims["aud"] = params["audience"]
+	}
+	if params["scope"] != "" {
+		claims["scope"] = params["scope"]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": s.issue(claims),
+		"scope":        params["scope"],
+		"expires_in":   int(s.expiry.Seconds()),
+		"token_type":   "Bearer",
+	})
+}
+
+func (s *Server) handleJwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.injected(w, JwksPath) {
+		return
+	}
+
+	keys := make([]map[string]string, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": k.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.key.E)).Bytes()),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":         s.URL + "/",
+		"token_endpoint": s.URL + TokenPath,
+		"jwks_uri":       s.URL + JwksPath,
+	})
+}
+
+// tokenParams reads a json or form encoded token request
+func tokenParams(r *http.Request) (map[string]string, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for k := range values {
+			params[k] = values.Get(k)
+		}
+		return params, nil
+	}
+
+	if err := json.Unmarshal(body, &params); err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,150 @@
+package auth0test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/auth0"
+	httpClient "github.com/LF-Engineering/dev-analytics-libraries/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func newClient(t *testing.T, s *Server) *auth0.ClientProvider {
+	client, err := auth0.NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "api", s.URL,
+		httpClient.NewClientProvider(5*time.Second), auth0.NewMemoryTokenStore(), nil, "test")
+	assert.NoError(t, err)
+	return client
+}
+
+func TestServerIssuesValidTokens(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetClient("id", "secret")
+	s.SetClaims(map[string]interface{}{"https://lfx.dev/claims/email": "bot@lfx.dev"})
+
+	client := newClient(t, s)
+	res, err := client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, auth0.RefreshSuccessful, res)
+
+	token, err := client.GetToken()
+	assert.NoError(t, err)
+	validator, err := auth0.NewValidator(auth0.ValidatorConfig{
+		JwksURL:  s.URL + JwksPath,
+		Issuer:   s.Issuer(),
+		Audience: []string{"api"},
+		Claims:   map[string]interface{}{"azp": "id", "https://lfx.dev/claims/email": "bot@lfx.dev"},
+	}, httpClient.NewClientProvider(5*time.Second))
+	assert.NoError(t, err)
+	_, err = validator.Validate(token)
+	assert.NoError(t, err)
+
+	res, err = client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, auth0.NotExpireSoon, res)
+	assert.Equal(t, 1, s.TokenRequests())
+}
+
+func TestServerRejectsUnknownClient(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetClient("other", "secret")
+
+	_, err := newClient(t, s).RefreshToken()
+	assert.Error(t, err)
+}
+
+func TestServerErrorInjection(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.FailTokenRequests(http.StatusTooManyRequests, 1, `{"error":"too_many_requests"}`)
+
+	client := newClient(t, s)
+	client.SetRatePolicy(auth0.RatePolicy{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	_, err := client.RefreshToken()
+	assert.Error(t, err)
+	health := client.Health()
+	assert.False(t, health.Healthy)
+	assert.Contains(t, health.LastError, "status 429")
+
+	time.Sleep(5 * time.Millisecond)
+	res, err := client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, auth0.RefreshSuccessful, res)
+	assert.Equal(t, 2, s.TokenRequests())
+}
+
+func TestServerShortExpiryRefresh(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetExpiry(30 * time.Minute)
+
+	client := newClient(t, s)
+	client.SetRatePolicy(auth0.RatePolicy{Burst: 2, Interval: time.Hour})
+	for i := 0; i < 2; i++ {
+		res, err := client.RefreshToken()
+		assert.NoError(t, err)
+		assert.Equal(t, auth0.RefreshSuccessful, res)
+	}
+	assert.Equal(t, 2, s.TokenRequests())
+}
+
+func TestServerKeyRotation(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	validator, err := auth0.NewValidator(auth0.ValidatorConfig{
+		JwksURL:    s.URL + JwksPath,
+		MinRefresh: time.Nanosecond,
+	}, httpClient.NewClientProvider(5*time.Second))
+	assert.NoError(t, err)
+
+	old := s.Issue(nil)
+	_, err = validator.Validate(old)
+	assert.NoError(t, err)
+
+	kid, err := s.RotateKey()
+	assert.NoError(t, err)
+	assert.Equal(t, kid, s.KeyID())
+	_, err = validator.Validate(s.Issue(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s.JwksRequests())
+
+	// tokens of a retired key are rejected once the jwks is refetched
+	s.RetireKeys()
+	validator, err = auth0.NewValidator(auth0.ValidatorConfig{JwksURL: s.URL + JwksPath}, httpClient.NewClientProvider(5*time.Second))
+	assert.NoError(t, err)
+	_, err = validator.Validate(old)
+	assert.Error(t, err)
+	_, err = validator.Validate(s.Issue(nil))
+	assert.NoError(t, err)
+
+	// a failed fetch is retried by the next validation, not after MinRefresh
+	s.FailJwksRequests(http.StatusServiceUnavailable, 1, "")
+	validator, err = auth0.NewValidator(auth0.ValidatorConfig{JwksURL: s.URL + JwksPath}, httpClient.NewClientProvider(5*time.Second))
+	assert.NoError(t, err)
+	requests := s.JwksRequests()
+	_, err = validator.Validate(s.Issue(nil))
+	assert.Error(t, err)
+	_, err = validator.Validate(s.Issue(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, requests+2, s.JwksRequests())
+}
+
+func TestServerDiscovery(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	provider, err := auth0.DiscoverProvider(httpClient.NewClientProvider(5*time.Second), s.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, s.URL+TokenPath, provider.TokenURL)
+	assert.Equal(t, s.URL+JwksPath, provider.JwksURL)
+
+	// the discovered provider sends form encoded requests, the server accepts both
+	client, err := auth0.NewProviderClient("test", provider, "id", "secret", "", httpClient.NewClientProvider(5*time.Second), auth0.NewMemoryTokenStore(), nil, "test")
+	assert.NoError(t, err)
+	res, err := client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, auth0.RefreshSuccessful, res)
+}
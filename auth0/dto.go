@@ -118,6 +118,9 @@ const (
 	tokenDoc              = "token"
 	auth0JwksCache        = "auth0-jwks-cache-"
 	jwksDoc               = "jwks"
+	auth0TokenLockIndex   = "auth0-token-lock-"
+	auth0TokenLock        = "auth0-token-generation-"
+	tokenLockTTL          = 2 * time.Minute
 )
 
 // RefreshResult ...
@@ -0,0 +1,204 @@
+package auth0
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/elastic"
+)
+
+// ESTokenStore keeps the cache in the auth0-token-cache, auth0-jwks-cache and last-auth0-token-request ES indices of an environment
+type ESTokenStore struct {
+	esClient    ESClientProvider
+	environment string
+	key         string
+}
+
+// NewESTokenStore ...
+func NewESTokenStore(esClient ESClientProvider, env string) *ESTokenStore {
+	return &ESTokenStore{
+		esClient:    esClient,
+		environment: env,
+	}
+}
+
+// ForKey returns a store keeping the entries of key in their own documents of the same indices
+func (s *ESTokenStore) ForKey(key TokenKey) TokenStore {
+	return &ESTokenStore{
+		esClient:    s.esClient,
+		environment: s.environment,
+		key:         key.ID(),
+	}
+}
+
+// docID suffixes the document ids with the store key, the default key keeps the historical ids
+func (s *ESTokenStore) docID(id string) string {
+	if s.key == "" {
+		return id
+	}
+	return id + "-" + s.key
+}
+
+// GetToken ...
+func (s *ESTokenStore) GetToken() (string, error) {
+	res, err := s.esClient.Search(strings.TrimSpace(auth0TokenCache+s.environment), idQuery(s.docID(tokenDoc)))
+	if err != nil {
+		return "", esStoreError(err)
+	}
+
+	var e ESTokenSchema
+	err = json.Unmarshal(res, &e)
+	if err != nil {
+		log.Println("repository: GetOauthToken: could not unmarshal the data", err)
+		return "", err
+	}
+
+	if len(e.Hits.Hits) > 0 {
+		data := e.Hits.Hits[0]
+		return data.Source.Token, nil
+	}
+
+	return "", ErrCacheMiss
+}
+
+// SetToken ...
+func (s *ESTokenStore) SetToken(token string) error {
+	log.Println("creating new auth token")
+	at := AuthToken{
+		Name:      "AuthToken",
+		Token:     token,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := s.upsert(fmt.Sprintf("%s%s", auth0TokenCache, s.environment), s.docID(tokenDoc), at)
+	if err != nil {
+		log.Println("could not write the data")
+		return err
+	}
+
+	return nil
+}
+
+// GetJwks ...
+func (s *ESTokenStore) GetJwks() (string, error) {
+	res, err := s.esClient.Search(strings.TrimSpace(auth0JwksCache+s.environment), idQuery(s.docID(jwksDoc)))
+	if err != nil {
+		return "", esStoreError(err)
+	}
+
+	var e ESJwksSchema
+	err = json.Unmarshal(res, &e)
+	if err != nil {
+		log.Println("repository: GetOauthJwks: could not unmarshal the data", err)
+		return "", err
+	}
+
+	if len(e.Hits.Hits) > 0 {
+		data := e.Hits.Hits[0]
+		return data.Source.Jwks, nil
+	}
+
+	return "", ErrCacheMiss
+}
+
+// SetJwks ...
+func (s *ESTokenStore) SetJwks(cert string) error {
+	log.Println("creating new auth jwks cert string")
+	at := AuthJwks{
+		Name:      "AuthJwks",
+		Jwks:      cert,
+		CreatedAt: time.Now().UTC(),
+	}
+	err := s.upsert(fmt.Sprintf("%s%s", auth0JwksCache, s.environment), s.docID(jwksDoc), at)
+	if err != nil {
+		log.Println("could not write the data", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetLastRequestDate ...
+func (s *ESTokenStore) GetLastRequestDate() (time.Time, error) {
+	res, err := s.esClient.Search(strings.TrimSpace(lastAuth0TokenRequest+s.environment), idQuery(s.docID(lastTokenDate)))
+	if err != nil {
+		return time.Time{}, esStoreError(err)
+	}
+
+	var e LastActionSchema
+	err = json.Unmarshal(res, &e)
+	if err != nil {
+		log.Println("repository: getLastActionDate failed", err)
+		return time.Time{}, err
+	}
+
+	if len(e.Hits.Hits) > 0 {
+		data := e.Hits.Hits[0]
+		return data.Source.Date, nil
+	}
+
+	return time.Time{}, ErrCacheMiss
+}
+
+// SetLastRequestDate ...
+func (s *ESTokenStore) SetLastRequestDate(date time.Time) error {
+	d := struct {
+		Date time.Time `json:"date"`
+	}{
+		Date: date,
+	}
+	bul := []elastic.BulkData{
+		{
+			IndexName: strings.TrimSpace(lastAuth0TokenRequest + s.environment),
+			ID:        s.docID(lastTokenDate),
+			Data:      d,
+		},
+	}
+	_, err := s.esClient.BulkInsert(bul)
+	if err != nil {
+		log.Println("could not write the data to elastic")
+		return err
+	}
+
+	return nil
+}
+
+// esStoreError maps a missing index to a cache miss
+func esStoreError(err error) error {
+	if err.Error() == "index doesn't exist" {
+		return ErrCacheMiss
+	}
+	return err
+}
+
+// idQuery searches the cache document id
+func idQuery(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"size": 1,
+		"query": map[string]interface{}{
+			"term": map[string]interface{}{
+				"_id": id,
+			},
+		},
+	}
+}
+
+// upsert writes the document id of index, creating it when missing
+func (s *ESTokenStore) upsert(index string, id string, body interface{}) error {
+	if upserter, ok := s.esClient.(ESUpsertProvider); ok {
+		_, err := upserter.UpsertDocument(index, id, body)
+		return err
+	}
+
+	if _, err := s.esClient.UpdateDocument(index, id, body); err == nil {
+		return nil
+	}
+	doc, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = s.esClient.CreateDocument(index, id, doc)
+	return err
+}
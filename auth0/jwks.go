@@ -1,13 +1,17 @@
 package auth0
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
-	"log"
+	"math/big"
 	"strings"
-	"time"
 
+	"github.com/LF-Engineering/dev-analytics-libraries/alert"
 	"github.com/dgrijalva/jwt-go"
 )
 
@@ -26,32 +30,75 @@ type JSONWebKeys struct {
 	X5c []string `json:"x5c"`
 }
 
-func (a *ClientProvider) createAuthJwks(cert string) error {
-	log.Println("creating new auth jwks cert string")
-	at := AuthJwks{
-		Name:      "AuthJwks",
-		Jwks:      cert,
-		CreatedAt: time.Now().UTC(),
+// PublicKey returns the RSA public key of the jwk, from its x5c certificate chain or from its n and e parameters
+func (k JSONWebKeys) PublicKey() (*rsa.PublicKey, error) {
+	if len(k.X5c) > 0 {
+		der, err := base64.StdEncoding.DecodeString(k.X5c[0])
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("jwk certificate is not an RSA key")
+		}
+		return key, nil
+	}
+
+	if k.Kty != "RSA" || k.N == "" || k.E == "" {
+		return nil, errors.New("jwk has neither x5c nor RSA n and e")
+	}
+	n, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+	if err != nil {
+		return nil, err
 	}
-	_, err := a.esClient.UpdateDocument(fmt.Sprintf("%s%s", auth0JwksCache, a.Environment), jwksDoc, at)
+	e, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
 	if err != nil {
-		log.Println("could not write the data", err)
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+// PEM returns the jwk as a PEM certificate when it has an x5c chain, as a PEM public key otherwise
+func (k JSONWebKeys) PEM() (string, error) {
+	if len(k.X5c) > 0 {
+		return "-----BEGIN CERTIFICATE-----\n" + k.X5c[0] + "\n-----END CERTIFICATE-----", nil
+	}
+
+	key, err := k.PublicKey()
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+func (a *ClientProvider) createAuthJwks(cert string) error {
+	return a.store.SetJwks(cert)
 }
 
 func (a *ClientProvider) getPemCert(token *jwt.Token, refreshJwks bool) (string, error) {
 	cert := ""
 	cert, err := a.getCachedJwks()
-	if err != nil {
+	// a missing cache is fine when the jwks is about to be refreshed, e.g. with a brand new token store
+	if err != nil && !refreshJwks {
 		return cert, err
 	}
 
 	// check if the refresh jwks cache flag coming from the refresh cron is set to true
 	if refreshJwks {
-		_, resp, err := a.httpClient.Request(fmt.Sprintf("%s/oauth/.well-known/jwks.json", a.AuthURL), "GET", nil, nil, nil)
+		_, resp, err := a.httpClient.Request(a.provider.JwksURL, "GET", nil, nil, nil)
 		if err != nil {
 			return cert, err
 		}
@@ -61,9 +108,14 @@ func (a *ClientProvider) getPemCert(token *jwt.Token, refreshJwks bool) (string,
 			return cert, err
 		}
 
+		// the cached cert is not the one of a kid missing from the jwks
+		cert = ""
 		for _, k := range jwks.Keys {
 			if token.Header["kid"] == k.Kid {
-				cert = "-----BEGIN CERTIFICATE-----\n" + k.X5c[0] + "\n-----END CERTIFICATE-----"
+				if cert, err = k.PEM(); err != nil {
+					return "", err
+				}
+				break
 			}
 		}
 
@@ -83,38 +135,14 @@ func (a *ClientProvider) getPemCert(token *jwt.Token, refreshJwks bool) (string,
 }
 
 func (a *ClientProvider) getCachedJwks() (string, error) {
-	res, err := a.esClient.Search(strings.TrimSpace(auth0JwksCache+a.Environment), searchJwksQuery)
-	if err != nil {
-		go func() {
-			errMsg := fmt.Sprintf("%s-%s: error cached jwks not found\n %s", a.appName, a.Environment, err)
-			err := a.slackClient.SendText(errMsg)
-			fmt.Println("Err: send to slack: ", err)
-		}()
-
-		return "", err
+	cert, err := a.store.GetJwks()
+	if err == ErrCacheMiss {
+		return "", errors.New("GetJwks: could not find the associated jwks")
 	}
-
-	var e ESJwksSchema
-	err = json.Unmarshal(res, &e)
 	if err != nil {
-		log.Println("repository: GetOauthJwks: could not unmarshal the data", err)
+		a.notify(alert.Warning, fmt.Sprintf("%s-%s: error cached jwks not found\n %s", a.appName, a.Environment, err))
 		return "", err
 	}
 
-	if len(e.Hits.Hits) > 0 {
-		data := e.Hits.Hits[0]
-
-		return data.Source.Jwks, nil
-	}
-
-	return "", errors.New("GetJwks: could not find the associated jwks")
-}
-
-var searchJwksQuery = map[string]interface{}{
-	"size": 1,
-	"query": map[string]interface{}{
-		"term": map[string]interface{}{
-			"_id": jwksDoc,
-		},
-	},
+	return cert, nil
 }
@@ -0,0 +1,144 @@
+package auth0
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when a token request is refused by the rate policy
+var ErrRateLimited = errors.New("auth0 token request rate limited")
+
+// RatePolicy limits the token requests sent to auth0.
+// Successful issuances are limited by a token bucket of Burst tokens regaining one every Interval,
+// failed requests are retried after a backoff doubling from MinBackoff up to MaxBackoff.
+type RatePolicy struct {
+	Burst      int
+	Interval   time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRatePolicy allows one issuance per hour and retries failures after 30s, 1m, 2m... up to 15m
+var DefaultRatePolicy = RatePolicy{
+	Burst:      1,
+	Interval:   time.Hour,
+	MinBackoff: 30 * time.Second,
+	MaxBackoff: 15 * time.Minute,
+}
+
+// AuthHealth reports the state of the token requests, for services health endpoints
+type AuthHealth struct {
+	Healthy             bool      `json:"healthy"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorAt         time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	NextAllowedAttempt  time.Time `json:"next_allowed_attempt,omitempty"`
+}
+
+// rateLimiter applies a RatePolicy. The bucket state is the date saved as the last request date in the
+// token store so that it is shared by all instances, the failures backoff is local to the process.
+type rateLimiter struct {
+	mu          sync.Mutex
+	policy      RatePolicy
+	failures    int
+	nextAttempt time.Time
+	health      AuthHealth
+}
+
+func newRateLimiter(policy RatePolicy) *rateLimiter {
+	return &rateLimiter{policy: normalizePolicy(policy), health: AuthHealth{Healthy: true}}
+}
+
+func normalizePolicy(policy RatePolicy) RatePolicy {
+	if policy.Burst <= 0 {
+		policy.Burst = DefaultRatePolicy.Burst
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = DefaultRatePolicy.Interval
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = DefaultRatePolicy.MinBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultRatePolicy.MaxBackoff
+	}
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = policy.MinBackoff
+	}
+
+	return policy
+}
+
+func (l *rateLimiter) setPolicy(policy RatePolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = normalizePolicy(policy)
+}
+
+// allow checks a new request at now given the bucket state last, and returns the state to save after a success.
+// last is the time the bucket was last emptied of one token: with a burst of one it is the last issuance date.
+func (l *rateLimiter) allow(last time.Time, now time.Time) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.nextAttempt) {
+		l.health.NextAllowedAttempt = l.nextAttempt
+		return last, fmt.Errorf("%w: backing off after %d failures until %s", ErrRateLimited, l.failures, l.nextAttempt.Format(time.RFC3339))
+	}
+
+	// generic cell rate algorithm, the next theoretical issuance is last + interval
+	allowedAt := last.Add(-time.Duration(l.policy.Burst-2) * l.policy.Interval)
+	if now.Before(allowedAt) {
+		l.health.NextAllowedAttempt = allowedAt
+		return last, fmt.Errorf("%w: next token can be requested at %s", ErrRateLimited, allowedAt.Format(time.RFC3339))
+	}
+
+	next := last.Add(l.policy.Interval)
+	if now.After(next) {
+		next = now
+	}
+
+	return next, nil
+}
+
+func (l *rateLimiter) success(now time.Time, state time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.failures = 0
+	l.nextAttempt = time.Time{}
+	l.health.Healthy = true
+	l.health.LastSuccess = now
+	l.health.ConsecutiveFailures = 0
+	l.health.NextAllowedAttempt = state.Add(-time.Duration(l.policy.Burst-2) * l.policy.Interval)
+}
+
+func (l *rateLimiter) failure(now time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	backoff := l.policy.MinBackoff
+	for i := 0; i < l.failures && backoff < l.policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > l.policy.MaxBackoff {
+		backoff = l.policy.MaxBackoff
+	}
+	l.failures++
+	l.nextAttempt = now.Add(backoff)
+
+	l.health.Healthy = false
+	l.health.LastError = err.Error()
+	l.health.LastErrorAt = now
+	l.health.ConsecutiveFailures = l.failures
+	l.health.NextAllowedAttempt = l.nextAttempt
+}
+
+func (l *rateLimiter) status() AuthHealth {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.health
+}
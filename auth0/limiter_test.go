@@ -0,0 +1,102 @@
+package auth0
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/alert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterBucket(t *testing.T) {
+	l := newRateLimiter(RatePolicy{Burst: 3, Interval: time.Hour})
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	// a full bucket allows a burst of three issuances
+	var state time.Time
+	for i := 0; i < 3; i++ {
+		next, err := l.allow(state, now)
+		assert.NoError(t, err)
+		state = next
+	}
+	_, err := l.allow(state, now)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+
+	// one issuance is regained per interval
+	state, err = l.allow(state, now.Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = l.allow(state, now.Add(time.Hour))
+	assert.True(t, errors.Is(err, ErrRateLimited))
+}
+
+func TestRateLimiterDefaultPolicy(t *testing.T) {
+	l := newRateLimiter(RatePolicy{})
+	assert.Equal(t, DefaultRatePolicy, l.policy)
+
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	state, err := l.allow(time.Time{}, now)
+	assert.NoError(t, err)
+	// with a burst of one the saved state is the issuance date, as before
+	assert.Equal(t, now, state)
+
+	_, err = l.allow(state, now.Add(59*time.Minute))
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	_, err = l.allow(state, now.Add(time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestRateLimiterBackoff(t *testing.T) {
+	l := newRateLimiter(RatePolicy{MinBackoff: time.Minute, MaxBackoff: 3 * time.Minute})
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, backoff := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		l.failure(now, errors.New("boom"))
+		health := l.status()
+		assert.False(t, health.Healthy)
+		assert.Equal(t, i+1, health.ConsecutiveFailures)
+		assert.Equal(t, now.Add(backoff), health.NextAllowedAttempt)
+
+		_, err := l.allow(time.Time{}, now.Add(backoff-time.Second))
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		_, err = l.allow(time.Time{}, now.Add(backoff))
+		assert.NoError(t, err)
+	}
+
+	l.success(now, now)
+	health := l.status()
+	assert.True(t, health.Healthy)
+	assert.Equal(t, 0, health.ConsecutiveFailures)
+	assert.Equal(t, "boom", health.LastError)
+	assert.Equal(t, now, health.LastSuccess)
+}
+
+func TestGenerateTokenFailureDoesNotBan(t *testing.T) {
+	server := newFakeTokenServer(t)
+	server.status = http.StatusUnauthorized
+	alerts := alert.NewRecorder()
+	client, err := NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "api", "https://auth", server, NewMemoryTokenStore(), alerts, "test")
+	assert.NoError(t, err)
+	client.SetRatePolicy(RatePolicy{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+
+	_, err = client.RefreshToken()
+	assert.Error(t, err)
+	health := client.Health()
+	assert.False(t, health.Healthy)
+	assert.Contains(t, health.LastError, "status 401")
+	assert.Len(t, alerts.Alerts(), 1)
+	assert.Equal(t, alert.Error, alerts.Alerts()[0].Severity)
+
+	// the failed request is not counted as an issuance
+	time.Sleep(5 * time.Millisecond)
+	server.status = 0
+	res, err := client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, RefreshSuccessful, res)
+	assert.True(t, client.Health().Healthy)
+
+	// successful issuances are rate limited
+	_, err = client.generateToken()
+	assert.True(t, errors.Is(err, ErrRateLimited))
+}
@@ -0,0 +1,251 @@
+package auth0
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/alert"
+	"github.com/LF-Engineering/dev-analytics-libraries/lock"
+)
+
+var keyCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+// TokenKey selects a token: the audience of the API it is used for and the requested scopes
+type TokenKey struct {
+	Audience string
+	Scopes   []string
+}
+
+// ID is a stable identifier of the key usable in cache names, the same for any scopes order.
+// It is the cleaned audience, for readability, followed by a hash of the whole key so that two keys never share an id.
+func (k TokenKey) ID() string {
+	id := strings.Trim(keyCleaner.ReplaceAllString(strings.ToLower(k.Audience), "-"), "-")
+	sum := sha1.Sum([]byte(k.Audience + "\n" + k.Scope()))
+
+	return id + "-" + hex.EncodeToString(sum[:])[:16]
+}
+
+// Scope returns the sorted scopes in the oauth space separated form
+func (k TokenKey) Scope() string {
+	scopes := append([]string(nil), k.Scopes...)
+	sort.Strings(scopes)
+	return strings.Join(scopes, " ")
+}
+
+// KeyedTokenStore is a TokenStore able to cache the entries of each token key separately
+type KeyedTokenStore interface {
+	ForKey(key TokenKey) TokenStore
+}
+
+// Tenant holds the credentials used to request tokens from an auth0 tenant, or from Provider when set
+type Tenant struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	Provider     *Provider
+}
+
+// MultiClientProvider manages the tokens of several audiences, possibly from different tenants.
+// Each (audience, scopes) key has its own cached token, last request date and generation lock.
+type MultiClientProvider struct {
+	Environment   string
+	defaultTenant Tenant
+	tenants       map[string]Tenant
+	httpClient    HTTPClientProvider
+	store         KeyedTokenStore
+	locker        lock.Locker
+	policy        RatePolicy
+	alerter       alert.Alerter
+	dispatcher    *alert.Dispatcher
+	appName       string
+
+	mu      sync.Mutex
+	clients map[string]*ClientProvider
+}
+
+// NewMultiClient creates a client requesting tokens from tenant unless the audience was added with AddTenant.
+// slackClient may be nil when no alerting is wanted, the clients of all keys share its alerts deduplication.
+// Call Close on shutdown to stop the alert dispatcher created for a plain slack client.
+func NewMultiClient(env string,
+	tenant Tenant,
+	httpClient HTTPClientProvider,
+	store KeyedTokenStore,
+	slackClient SlackProvider,
+	appName string) (*MultiClientProvider, error) {
+	if store == nil {
+		return nil, errors.New("NewMultiClient: token store is nil")
+	}
+
+	// an ES store shares the token generation locks of every key between all instances using the same ES cluster
+	var locker lock.Locker = lock.NewMemoryLocker(lockOwner(appName))
+	if esStore, ok := store.(*ESTokenStore); ok {
+		locker = esLocker("NewMultiClient", esStore.esClient, env, appName)
+	}
+
+	multi := &MultiClientProvider{
+		Environment:   env,
+		defaultTenant: tenant,
+		tenants:       make(map[string]Tenant),
+		httpClient:    httpClient,
+		store:         store,
+		locker:        locker,
+		policy:        DefaultRatePolicy,
+		alerter:       alert.Wrap(slackClient),
+		appName:       appName,
+		clients:       make(map[string]*ClientProvider),
+	}
+	multi.dispatcher = ownDispatcher(slackClient, multi.alerter)
+
+	return multi, nil
+}
+
+// SetLocker replaces the lock guarding token generation, e.g. to share it between instances
+func (m *MultiClientProvider) SetLocker(locker lock.Locker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.locker = locker
+	for _, c := range m.clients {
+		c.SetLocker(locker)
+	}
+}
+
+// SetRatePolicy replaces DefaultRatePolicy for the token requests of every key, each key is limited separately
+func (m *MultiClientProvider) SetRatePolicy(policy RatePolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = policy
+	for _, c := range m.clients {
+		c.SetRatePolicy(policy)
+	}
+}
+
+// Health reports the token requests state of every key used so far, by key id
+func (m *MultiClientProvider) Health() map[string]AuthHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	health := make(map[string]AuthHealth, len(m.clients))
+	for id, c := range m.clients {
+		health[id] = c.Health()
+	}
+
+	return health
+}
+
+// FlushAlerts waits until the pending alerts are sent, call it on shutdown
+func (m *MultiClientProvider) FlushAlerts(timeout time.Duration) error {
+	if m.alerter == nil {
+		return nil
+	}
+	return m.alerter.Flush(timeout)
+}
+
+// Close sends the pending alerts and stops the alert dispatcher created for the slack client, if any
+func (m *MultiClientProvider) Close(timeout time.Duration) error {
+	if m.dispatcher == nil {
+		return nil
+	}
+	return m.dispatcher.Close(timeout)
+}
+
+// AddTenant requests the tokens of audience from tenant
+func (m *MultiClientProvider) AddTenant(audience string, tenant Tenant) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenants[audience] = tenant
+}
+
+// Client returns the client of key, usable wherever a single audience auth0 client is expected
+func (m *MultiClientProvider) Client(key TokenKey) (*ClientProvider, error) {
+	if key.Audience == "" {
+		return nil, errors.New("Client: audience is empty")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := key.ID()
+	if c, ok := m.clients[id]; ok {
+		return c, nil
+	}
+
+	tenant, ok := m.tenants[key.Audience]
+	if !ok {
+		tenant = m.defaultTenant
+	}
+	c, err := NewAuth0ClientWithStore(m.Environment,
+		tenant.GrantType,
+		tenant.ClientID,
+		tenant.ClientSecret,
+		key.Audience,
+		tenant.AuthURL,
+		m.httpClient,
+		m.store.ForKey(key),
+		m.alerter,
+		m.appName)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.Provider != nil {
+		c.provider = *tenant.Provider
+		c.SetRatePolicy(tenant.Provider.RatePolicy)
+	} else {
+		c.SetRatePolicy(m.policy)
+	}
+	c.AuthScope = key.Scope()
+	c.cacheKey = id
+	c.locker = m.locker
+	m.clients[id] = c
+
+	return c, nil
+}
+
+// GetToken returns the cached token of key
+func (m *MultiClientProvider) GetToken(key TokenKey) (string, error) {
+	c, err := m.Client(key)
+	if err != nil {
+		return "", err
+	}
+
+	return c.GetToken()
+}
+
+// RefreshToken refreshes the token of key when it expires soon
+func (m *MultiClientProvider) RefreshToken(key TokenKey) (RefreshResult, error) {
+	c, err := m.Client(key)
+	if err != nil {
+		return RefreshError, err
+	}
+
+	return c.RefreshToken()
+}
+
+// RefreshTokens refreshes the tokens of all the keys used so far, e.g. from a refresh cron
+func (m *MultiClientProvider) RefreshTokens() error {
+	m.mu.Lock()
+	clients := make(map[string]*ClientProvider, len(m.clients))
+	for id, c := range m.clients {
+		clients[id] = c
+	}
+	m.mu.Unlock()
+
+	var failed []string
+	for id, c := range clients {
+		if _, err := c.RefreshToken(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", id, err))
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("RefreshTokens: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package auth0
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTokenServer issues RS256 tokens for the requested audience and serves their jwks
+type fakeTokenServer struct {
+	jwks     *fakeJwksServer
+	payloads []map[string]string
+	status   int
+}
+
+func newFakeTokenServer(t *testing.T) *fakeTokenServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return &fakeTokenServer{jwks: &fakeJwksServer{keys: map[string]*rsa.PrivateKey{"key1": key}}}
+}
+
+func (f *fakeTokenServer) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+	if strings.HasSuffix(url, "/jwks.json") {
+		return f.jwks.Request(url, method, header, body, params)
+	}
+
+	payload := map[string]string{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	f.payloads = append(f.payloads, payload)
+	if f.status != 0 {
+		return f.status, []byte(`{"error":"access_denied"}`), nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   url,
+		"aud":   payload["audience"],
+		"azp":   payload["client_id"],
+		"scope": payload["scope"],
+		"exp":   time.Now().Add(24 * time.Hour).Unix(),
+	})
+	token.Header["kid"] = "key1"
+	signed, err := token.SignedString(f.jwks.keys["key1"])
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	resp, err := json.Marshal(Resp{AccessToken: signed, TokenType: "Bearer", ExpiresIn: 86400})
+	return http.StatusOK, resp, err
+}
+
+func tokenClaims(t *testing.T, token string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	_, _, err := new(jwt.Parser).ParseUnverified(token, claims)
+	assert.NoError(t, err)
+	return claims
+}
+
+func TestTokenKeyID(t *testing.T) {
+	a := TokenKey{Audience: "https://api.lfx.dev/orgs", Scopes: []string{"read:orgs", "write:orgs"}}
+	b := TokenKey{Audience: "https://api.lfx.dev/orgs", Scopes: []string{"write:orgs", "read:orgs"}}
+	c := TokenKey{Audience: "https://api.lfx.dev/orgs"}
+
+	assert.Equal(t, a.ID(), b.ID())
+	assert.Equal(t, "read:orgs write:orgs", b.Scope())
+	assert.True(t, strings.HasPrefix(c.ID(), "https-api-lfx-dev-orgs-"))
+	assert.NotEqual(t, a.ID(), c.ID())
+
+	// audiences cleaned the same way do not share an id
+	d := TokenKey{Audience: "https://api-lfx-dev/orgs"}
+	assert.NotEqual(t, c.ID(), d.ID())
+}
+
+func TestMultiClientProvider(t *testing.T) {
+	server := newFakeTokenServer(t)
+	store := NewMemoryTokenStore()
+	multi, err := NewMultiClient("test", Tenant{GrantType: "client_credentials", ClientID: "default", AuthURL: "https://auth"}, server, store, nil, "test")
+	assert.NoError(t, err)
+	multi.AddTenant("users", Tenant{GrantType: "client_credentials", ClientID: "other", AuthURL: "https://other-auth"})
+
+	orgs := TokenKey{Audience: "orgs", Scopes: []string{"read:orgs"}}
+	users := TokenKey{Audience: "users"}
+
+	res, err := multi.RefreshToken(orgs)
+	assert.NoError(t, err)
+	assert.Equal(t, RefreshSuccessful, res)
+	// the other key is not rate limited by the first token request
+	res, err = multi.RefreshToken(users)
+	assert.NoError(t, err)
+	assert.Equal(t, RefreshSuccessful, res)
+
+	orgsToken, err := multi.GetToken(orgs)
+	assert.NoError(t, err)
+	claims := tokenClaims(t, orgsToken)
+	assert.Equal(t, "orgs", claims["aud"])
+	assert.Equal(t, "default", claims["azp"])
+	assert.Equal(t, "read:orgs", claims["scope"])
+
+	usersToken, err := multi.GetToken(users)
+	assert.NoError(t, err)
+	claims = tokenClaims(t, usersToken)
+	assert.Equal(t, "users", claims["aud"])
+	assert.Equal(t, "other", claims["azp"])
+	assert.Equal(t, "https://other-auth/oauth/token", claims["iss"])
+
+	// tokens are cached per key
+	cached, err := store.ForKey(orgs).GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, orgsToken, cached)
+	_, err = store.GetToken()
+	assert.Equal(t, ErrCacheMiss, err)
+
+	assert.Len(t, server.payloads, 2)
+	_, ok := server.payloads[1]["scope"]
+	assert.False(t, ok)
+
+	// the same client is returned for the key
+	c1, err := multi.Client(orgs)
+	assert.NoError(t, err)
+	c2, err := multi.Client(TokenKey{Audience: "orgs", Scopes: []string{"read:orgs"}})
+	assert.NoError(t, err)
+	assert.True(t, c1 == c2)
+
+	assert.NoError(t, multi.RefreshTokens())
+	assert.Len(t, server.payloads, 2)
+
+	_, err = multi.Client(TokenKey{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,286 @@
+package auth0
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Provider describes how to request and verify the tokens of an OAuth2 identity provider.
+// Tokens are verified against JwksURL, providers issuing opaque tokens leave it empty and
+// the token expiry is then taken from the token response.
+type Provider struct {
+	Name     string
+	Issuer   string
+	TokenURL string
+	JwksURL  string
+	// FormEncoded sends the token request as application/x-www-form-urlencoded instead of json
+	FormEncoded bool
+	// AudienceParam is the token request parameter carrying the audience, empty when the provider takes none
+	AudienceParam string
+	ExtraParams   map[string]string
+	// RefreshBefore is how long before expiry RefreshToken renews the token, 60 minutes when zero
+	RefreshBefore time.Duration
+	// RatePolicy of the token requests, DefaultRatePolicy when zero
+	RatePolicy RatePolicy
+	// RequestToken replaces the client credentials request for non standard providers
+	RequestToken func(httpClient HTTPClientProvider) (Resp, error)
+}
+
+// Auth0Provider is the preset of an auth0 tenant, authURL is like https://<tenant>.auth0.com
+func Auth0Provider(authURL string) Provider {
+	authURL = strings.TrimSuffix(authURL, "/")
+	return Provider{
+		Name:          "auth0",
+		Issuer:        authURL + "/",
+		TokenURL:      authURL + "/oauth/token",
+		JwksURL:       authURL + "/oauth/.well-known/jwks.json",
+		AudienceParam: "audience",
+	}
+}
+
+// KeycloakProvider is the preset of a keycloak realm, baseURL is like https://keycloak.example.org
+func KeycloakProvider(baseURL, realm string) Provider {
+	issuer := strings.TrimSuffix(baseURL, "/") + "/realms/" + url.PathEscape(realm)
+	return Provider{
+		Name:        "keycloak",
+		Issuer:      issuer,
+		TokenURL:    issuer + "/protocol/openid-connect/token",
+		JwksURL:     issuer + "/protocol/openid-connect/certs",
+		FormEncoded: true,
+	}
+}
+
+// GitHubAppProvider requests installation tokens of a GitHub App, which are opaque and valid for one hour.
+// apiURL is https://api.github.com unless using GitHub Enterprise, privateKey is the app PEM private key.
+func GitHubAppProvider(apiURL, appID, installationID string, privateKey []byte) (Provider, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return Provider{}, err
+	}
+	tokenURL := fmt.Sprintf("%s/app/installations/%s/access_tokens", strings.TrimSuffix(apiURL, "/"), url.PathEscape(installationID))
+
+	return Provider{
+		Name:          "github-app",
+		TokenURL:      tokenURL,
+		RefreshBefore: 10 * time.Minute,
+		RatePolicy:    RatePolicy{Burst: 2, Interval: 30 * time.Minute},
+		RequestToken: func(httpClient HTTPClientProvider) (Resp, error) {
+			return requestGitHubAppToken(httpClient, tokenURL, appID, key)
+		},
+	}, nil
+}
+
+// DiscoverProvider reads the OpenID configuration of issuer
+func DiscoverProvider(httpClient HTTPClientProvider, issuer string) (Provider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	statusCode, resp, err := httpClient.Request(issuer+"/.well-known/openid-configuration", "GET", nil, nil, nil)
+	if err != nil {
+		return Provider{}, err
+	}
+	if statusCode != http.StatusOK {
+		return Provider{}, fmt.Errorf("DiscoverProvider: could not read the openid configuration of %s: status %d", issuer, statusCode)
+	}
+
+	var config struct {
+		Issuer        string `json:"issuer"`
+		TokenEndpoint string `json:"token_endpoint"`
+		JwksURI       string `json:"jwks_uri"`
+	}
+	if err := json.Unmarshal(resp, &config); err != nil {
+		return Provider{}, err
+	}
+	if config.TokenEndpoint == "" {
+		return Provider{}, errors.New("DiscoverProvider: the openid configuration has no token endpoint")
+	}
+
+	return Provider{
+		Name:        "oidc",
+		Issuer:      config.Issuer,
+		TokenURL:    config.TokenEndpoint,
+		JwksURL:     config.JwksURI,
+		FormEncoded: true,
+	}, nil
+}
+
+// NewProviderClient creates a client credentials client of any provider, sharing the caching, locking,
+// rate limiting and refresh logic of the auth0 client
+func NewProviderClient(env string,
+	provider Provider,
+	clientID,
+	clientSecret,
+	audience string,
+	httpClient HTTPClientProvider,
+	store TokenStore,
+	slackClient SlackProvider,
+	appName string) (*ClientProvider, error) {
+	if provider.TokenURL == "" && provider.RequestToken == nil {
+		return nil, errors.New("NewProviderClient: provider has no token url")
+	}
+	client, err := NewAuth0ClientWithStore(env,
+		"client_credentials",
+		clientID,
+		clientSecret,
+		audience,
+		provider.Issuer,
+		httpClient,
+		store,
+		slackClient,
+		appName)
+	if err != nil {
+		return nil, err
+	}
+	client.provider = provider
+	client.SetRatePolicy(provider.RatePolicy)
+
+	return client, nil
+}
+
+// requestToken sends the client credentials request of the provider
+func (a *ClientProvider) requestToken() (Resp, error) {
+	if a.provider.RequestToken != nil {
+		return a.provider.RequestToken(a.httpClient)
+	}
+
+	params := map[string]string{
+		"grant_type":    a.AuthGrantType,
+		"client_id":     a.AuthClientID,
+		"client_secret": a.AuthClientSecret,
+	}
+	if a.provider.AudienceParam != "" && a.AuthAudience != "" {
+		params[a.provider.AudienceParam] = a.AuthAudience
+	}
+	if a.AuthScope != "" {
+		params["scope"] = a.AuthScope
+	}
+	for k, v := range a.provider.ExtraParams {
+		params[k] = v
+	}
+
+	var headers map[string]string
+	var body []byte
+	if a.provider.FormEncoded {
+		values := url.Values{}
+		for k, v := range params {
+			values.Set(k, v)
+		}
+		headers = map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+		body = []byte(values.Encode())
+	} else {
+		var err error
+		if body, err = json.Marshal(params); err != nil {
+			return Resp{}, err
+		}
+	}
+
+	statusCode, response, err := a.httpClient.Request(a.provider.TokenURL, "POST", headers, body, nil)
+	if err != nil {
+		return Resp{}, err
+	}
+	if statusCode != http.StatusOK {
+		return Resp{}, fmt.Errorf("token request failed with status %d: %s", statusCode, string(response))
+	}
+
+	var result Resp
+	if err := json.Unmarshal(response, &result); err != nil {
+		return Resp{}, err
+	}
+
+	return result, nil
+}
+
+// requestGitHubAppToken exchanges a jwt signed by the app key for an installation token
+func requestGitHubAppToken(httpClient HTTPClientProvider, tokenURL, appID string, key *rsa.PrivateKey) (Resp, error) {
+	now := time.Now()
+	appToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{
+		Issuer:    appID,
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(9 * time.Minute).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return Resp{}, err
+	}
+
+	headers := map[string]string{
+		"Authorization": fmt.Sprintf("%s %s", "Bearer", appToken),
+		"Accept":        "application/vnd.github.v3+json",
+	}
+	statusCode, response, err := httpClient.Request(tokenURL, "POST", headers, nil, nil)
+	if err != nil {
+		return Resp{}, err
+	}
+	if statusCode != http.StatusCreated && statusCode != http.StatusOK {
+		return Resp{}, fmt.Errorf("installation token request failed with status %d: %s", statusCode, string(response))
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(response, &result); err != nil {
+		return Resp{}, err
+	}
+
+	return Resp{
+		AccessToken: result.Token,
+		TokenType:   "token",
+		ExpiresIn:   int(time.Until(result.ExpiresAt).Seconds()),
+	}, nil
+}
+
+// opaqueToken is the cache entry of a token that can not be verified, with the expiry of the token response
+type opaqueToken struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// verify checks a cache entry and returns its token and expiry
+func (a *ClientProvider) verify(entry string, refreshJwks bool) (string, time.Time, error) {
+	if a.provider.JwksURL == "" {
+		var token opaqueToken
+		if err := json.Unmarshal([]byte(entry), &token); err != nil {
+			return "", time.Time{}, err
+		}
+		if token.AccessToken == "" || !time.Now().Before(token.ExpiresAt) {
+			return "", time.Time{}, errors.New("token is expired")
+		}
+		return token.AccessToken, token.ExpiresAt, nil
+	}
+
+	ok, claims, err := a.isValid(entry, refreshJwks)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !ok {
+		return "", time.Time{}, errors.New("token is not valid")
+	}
+
+	return entry, tokenExpiry(claims), nil
+}
+
+// cacheEntry returns what is cached for a token response
+func (a *ClientProvider) cacheEntry(result Resp) (string, error) {
+	if a.provider.JwksURL != "" {
+		return result.AccessToken, nil
+	}
+
+	entry, err := json.Marshal(opaqueToken{
+		AccessToken: result.AccessToken,
+		ExpiresAt:   time.Now().UTC().Add(time.Duration(result.ExpiresIn) * time.Second),
+	})
+	return string(entry), err
+}
+
+func (a *ClientProvider) refreshBefore() time.Duration {
+	if a.provider.RefreshBefore > 0 {
+		return a.provider.RefreshBefore
+	}
+	return DefaultRefreshBefore
+}
@@ -0,0 +1,137 @@
+package auth0
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// requestFunc is an HTTPClientProvider answering with a function
+type requestFunc func(url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error)
+
+func (f requestFunc) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+	return f(url, method, header, body, params)
+}
+
+func TestKeycloakProviderClient(t *testing.T) {
+	server := newFakeTokenServer(t)
+	var tokenHeader map[string]string
+	var form url.Values
+	httpClient := requestFunc(func(u string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+		if strings.HasSuffix(u, "/certs") {
+			return server.jwks.Request(u, method, header, body, params)
+		}
+		assert.Equal(t, "https://keycloak/realms/lfx/protocol/openid-connect/token", u)
+		tokenHeader = header
+		var err error
+		form, err = url.ParseQuery(string(body))
+		assert.NoError(t, err)
+		payload, _ := json.Marshal(map[string]string{"client_id": form.Get("client_id"), "audience": form.Get("audience")})
+		return server.Request(u, method, nil, payload, nil)
+	})
+
+	client, err := NewProviderClient("test", KeycloakProvider("https://keycloak/", "lfx"), "id", "secret", "api", httpClient, NewMemoryTokenStore(), nil, "test")
+	assert.NoError(t, err)
+
+	res, err := client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, RefreshSuccessful, res)
+	assert.Equal(t, "application/x-www-form-urlencoded", tokenHeader["Content-Type"])
+	assert.Equal(t, "client_credentials", form.Get("grant_type"))
+	assert.Equal(t, "secret", form.Get("client_secret"))
+	// keycloak takes no audience parameter
+	_, ok := form["audience"]
+	assert.False(t, ok)
+
+	token, err := client.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "id", tokenClaims(t, token)["azp"])
+}
+
+func TestDiscoverProvider(t *testing.T) {
+	httpClient := requestFunc(func(u string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+		if u != "https://idp/.well-known/openid-configuration" {
+			return http.StatusNotFound, nil, nil
+		}
+		return http.StatusOK, []byte(`{"issuer":"https://idp/","token_endpoint":"https://idp/token","jwks_uri":"https://idp/keys"}`), nil
+	})
+
+	provider, err := DiscoverProvider(httpClient, "https://idp/")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://idp/", provider.Issuer)
+	assert.Equal(t, "https://idp/token", provider.TokenURL)
+	assert.Equal(t, "https://idp/keys", provider.JwksURL)
+	assert.True(t, provider.FormEncoded)
+
+	_, err = DiscoverProvider(httpClient, "https://other")
+	assert.Error(t, err)
+}
+
+func TestAuth0Provider(t *testing.T) {
+	provider := Auth0Provider("https://tenant.auth0.com/")
+	assert.Equal(t, "https://tenant.auth0.com/oauth/token", provider.TokenURL)
+	assert.Equal(t, "https://tenant.auth0.com/oauth/.well-known/jwks.json", provider.JwksURL)
+	assert.Equal(t, "audience", provider.AudienceParam)
+	assert.False(t, provider.FormEncoded)
+}
+
+func TestGitHubAppProviderClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	requests := 0
+	httpClient := requestFunc(func(u string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+		requests++
+		assert.Equal(t, "https://api.github.com/app/installations/42/access_tokens", u)
+		assert.Equal(t, "POST", method)
+
+		appToken := strings.TrimPrefix(header["Authorization"], "Bearer ")
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(appToken, claims, func(t *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "1234", claims["iss"])
+
+		expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		return http.StatusCreated, []byte(fmt.Sprintf(`{"token":"ghs_installation","expires_at":"%s"}`, expiresAt)), nil
+	})
+
+	provider, err := GitHubAppProvider("https://api.github.com", "1234", "42", privateKey)
+	assert.NoError(t, err)
+	client, err := NewProviderClient("test", provider, "", "", "", httpClient, NewMemoryTokenStore(), nil, "test")
+	assert.NoError(t, err)
+
+	res, err := client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, RefreshSuccessful, res)
+	token, err := client.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", token)
+
+	// the opaque token expiry comes from the response
+	res, err = client.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, NotExpireSoon, res)
+	assert.Equal(t, 1, requests)
+
+	ts, err := client.NewTokenSource(0).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "ghs_installation", ts.AccessToken)
+	assert.Equal(t, 1, requests)
+
+	_, err = GitHubAppProvider("https://api.github.com", "1234", "42", []byte("not a key"))
+	assert.Error(t, err)
+}
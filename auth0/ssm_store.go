@@ -0,0 +1,147 @@
+package auth0
+
+import (
+	"errors"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/aws/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+const (
+	ssmTokenParam           = "token"
+	ssmJwksParam            = "jwks"
+	ssmLastRequestDateParam = "last-token-date"
+)
+
+// SSMClientProvider reads and writes SSM SecureString parameters, a missing parameter being a *types.ParameterNotFound error
+type SSMClientProvider interface {
+	GetParameter(name string) (string, error)
+	// PutParameter creates the parameter, or overwrites it when overwrite is true
+	PutParameter(name string, value string, overwrite bool) error
+}
+
+// SSMTokenStore keeps the cache in SSM SecureString parameters named <prefix>/<env>/token, jwks and last-token-date
+type SSMTokenStore struct {
+	ssmClient   SSMClientProvider
+	prefix      string
+	environment string
+	key         string
+}
+
+// NewSSMTokenStore creates a store using the default aws configuration, prefix is like /auth0-cache
+func NewSSMTokenStore(prefix string, env string) (*SSMTokenStore, error) {
+	s, err := ssm.NewSSMClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSSMTokenStoreWithClient(&ssmParams{client: s}, prefix, env), nil
+}
+
+// NewSSMTokenStoreWithClient creates a store keeping its parameters with ssmClient
+func NewSSMTokenStoreWithClient(ssmClient SSMClientProvider, prefix string, env string) *SSMTokenStore {
+	return &SSMTokenStore{
+		ssmClient:   ssmClient,
+		prefix:      prefix,
+		environment: env,
+	}
+}
+
+// ForKey returns a store keeping the parameters of key under <prefix>/<env>/<key>
+func (s *SSMTokenStore) ForKey(key TokenKey) TokenStore {
+	return &SSMTokenStore{
+		ssmClient:   s.ssmClient,
+		prefix:      s.prefix,
+		environment: s.environment,
+		key:         key.ID(),
+	}
+}
+
+// GetToken ...
+func (s *SSMTokenStore) GetToken() (string, error) {
+	return s.get(ssmTokenParam)
+}
+
+// SetToken ...
+func (s *SSMTokenStore) SetToken(token string) error {
+	return s.set(ssmTokenParam, token)
+}
+
+// GetJwks ...
+func (s *SSMTokenStore) GetJwks() (string, error) {
+	return s.get(ssmJwksParam)
+}
+
+// SetJwks ...
+func (s *SSMTokenStore) SetJwks(cert string) error {
+	return s.set(ssmJwksParam, cert)
+}
+
+// GetLastRequestDate ...
+func (s *SSMTokenStore) GetLastRequestDate() (time.Time, error) {
+	v, err := s.get(ssmLastRequestDateParam)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+// SetLastRequestDate ...
+func (s *SSMTokenStore) SetLastRequestDate(date time.Time) error {
+	return s.set(ssmLastRequestDateParam, date.UTC().Format(time.RFC3339Nano))
+}
+
+func (s *SSMTokenStore) name(param string) string {
+	if s.key == "" {
+		return s.prefix + "/" + s.environment + "/" + param
+	}
+	return s.prefix + "/" + s.environment + "/" + s.key + "/" + param
+}
+
+func (s *SSMTokenStore) get(param string) (string, error) {
+	v, err := s.ssmClient.GetParameter(s.name(param))
+	var notFound *types.ParameterNotFound
+	if errors.As(err, &notFound) {
+		return "", ErrCacheMiss
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return v, nil
+}
+
+func (s *SSMTokenStore) set(param string, value string) error {
+	// a parameter type is required when creating the parameter, not when overwriting it
+	_, err := s.get(param)
+	if err == ErrCacheMiss {
+		return s.ssmClient.PutParameter(s.name(param), value, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.ssmClient.PutParameter(s.name(param), value, true)
+}
+
+// ssmParams is the SSMClientProvider of a ssm.SSM client
+type ssmParams struct {
+	client *ssm.SSM
+}
+
+// GetParameter ...
+func (p *ssmParams) GetParameter(name string) (string, error) {
+	return p.client.Param(name, true, false, "", "", "").GetValue()
+}
+
+// PutParameter ...
+func (p *ssmParams) PutParameter(name string, value string, overwrite bool) error {
+	if overwrite {
+		_, err := p.client.Param(name, true, true, "text", "", value).UpdateValue()
+		return err
+	}
+	_, err := p.client.Param(name, true, false, "text", string(types.ParameterTypeSecureString), value).SetValue()
+	return err
+}
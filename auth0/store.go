@@ -0,0 +1,244 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned by a TokenStore when the requested entry is not cached
+var ErrCacheMiss = errors.New("auth0 cache entry not found")
+
+// TokenStore caches the auth0 token, the jwks cert and the date of the last token request
+type TokenStore interface {
+	GetToken() (string, error)
+	SetToken(token string) error
+	GetJwks() (string, error)
+	SetJwks(cert string) error
+	GetLastRequestDate() (time.Time, error)
+	SetLastRequestDate(date time.Time) error
+}
+
+// cacheEntries is the content of the in memory and file stores
+type cacheEntries struct {
+	Token           string    `json:"token,omitempty"`
+	Jwks            string    `json:"jwks,omitempty"`
+	LastRequestDate time.Time `json:"last_request_date,omitempty"`
+}
+
+// MemoryTokenStore keeps the cache in process memory, for tests and single process tools
+type MemoryTokenStore struct {
+	mu      sync.RWMutex
+	entries cacheEntries
+	keyed   map[string]*MemoryTokenStore
+}
+
+// NewMemoryTokenStore ...
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// ForKey returns the store of key, the same one on every call
+func (s *MemoryTokenStore) ForKey(key TokenKey) TokenStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keyed == nil {
+		s.keyed = make(map[string]*MemoryTokenStore)
+	}
+	id := key.ID()
+	if _, ok := s.keyed[id]; !ok {
+		s.keyed[id] = NewMemoryTokenStore()
+	}
+	return s.keyed[id]
+}
+
+// GetToken ...
+func (s *MemoryTokenStore) GetToken() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.entries.Token == "" {
+		return "", ErrCacheMiss
+	}
+	return s.entries.Token, nil
+}
+
+// SetToken ...
+func (s *MemoryTokenStore) SetToken(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Token = token
+	return nil
+}
+
+// GetJwks ...
+func (s *MemoryTokenStore) GetJwks() (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.entries.Jwks == "" {
+		return "", ErrCacheMiss
+	}
+	return s.entries.Jwks, nil
+}
+
+// SetJwks ...
+func (s *MemoryTokenStore) SetJwks(cert string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Jwks = cert
+	return nil
+}
+
+// GetLastRequestDate ...
+func (s *MemoryTokenStore) GetLastRequestDate() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.entries.LastRequestDate.IsZero() {
+		return time.Time{}, ErrCacheMiss
+	}
+	return s.entries.LastRequestDate, nil
+}
+
+// SetLastRequestDate ...
+func (s *MemoryTokenStore) SetLastRequestDate(date time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.LastRequestDate = date
+	return nil
+}
+
+// FileTokenStore keeps the cache in a json file readable only by its owner, for CLIs
+type FileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileTokenStore creates a store writing to path, the file is created on first write
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// ForKey returns a store writing the entries of key next to the store file, cache.json becomes cache-<key>.json
+func (s *FileTokenStore) ForKey(key TokenKey) TokenStore {
+	ext := filepath.Ext(s.path)
+	return NewFileTokenStore(strings.TrimSuffix(s.path, ext) + "-" + key.ID() + ext)
+}
+
+// GetToken ...
+func (s *FileTokenStore) GetToken() (string, error) {
+	entries, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	if entries.Token == "" {
+		return "", ErrCacheMiss
+	}
+	return entries.Token, nil
+}
+
+// SetToken ...
+func (s *FileTokenStore) SetToken(token string) error {
+	return s.update(func(entries *cacheEntries) {
+		entries.Token = token
+	})
+}
+
+// GetJwks ...
+func (s *FileTokenStore) GetJwks() (string, error) {
+	entries, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	if entries.Jwks == "" {
+		return "", ErrCacheMiss
+	}
+	return entries.Jwks, nil
+}
+
+// SetJwks ...
+func (s *FileTokenStore) SetJwks(cert string) error {
+	return s.update(func(entries *cacheEntries) {
+		entries.Jwks = cert
+	})
+}
+
+// GetLastRequestDate ...
+func (s *FileTokenStore) GetLastRequestDate() (time.Time, error) {
+	entries, err := s.read()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if entries.LastRequestDate.IsZero() {
+		return time.Time{}, ErrCacheMiss
+	}
+	return entries.LastRequestDate, nil
+}
+
+// SetLastRequestDate ...
+func (s *FileTokenStore) SetLastRequestDate(date time.Time) error {
+	return s.update(func(entries *cacheEntries) {
+		entries.LastRequestDate = date
+	})
+}
+
+func (s *FileTokenStore) read() (cacheEntries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *FileTokenStore) update(change func(entries *cacheEntries)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load()
+	if err != nil && err != ErrCacheMiss {
+		return err
+	}
+	change(&entries)
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see a partial cache
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0600); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileTokenStore) load() (cacheEntries, error) {
+	var entries cacheEntries
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return entries, ErrCacheMiss
+	}
+	if err != nil {
+		return entries, err
+	}
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return entries, err
+	}
+	return entries, nil
+}
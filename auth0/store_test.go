@@ -0,0 +1,322 @@
+package auth0
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/alert"
+	"github.com/LF-Engineering/dev-analytics-libraries/elastic"
+	"github.com/LF-Engineering/dev-analytics-libraries/lock"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTokenStore(t *testing.T, store TokenStore) {
+	_, err := store.GetToken()
+	assert.Equal(t, ErrCacheMiss, err)
+	_, err = store.GetJwks()
+	assert.Equal(t, ErrCacheMiss, err)
+	_, err = store.GetLastRequestDate()
+	assert.Equal(t, ErrCacheMiss, err)
+
+	date := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.SetToken("token"))
+	assert.NoError(t, store.SetJwks("cert"))
+	assert.NoError(t, store.SetLastRequestDate(date))
+
+	token, err := store.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token)
+	cert, err := store.GetJwks()
+	assert.NoError(t, err)
+	assert.Equal(t, "cert", cert)
+	d, err := store.GetLastRequestDate()
+	assert.NoError(t, err)
+	assert.True(t, date.Equal(d))
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth0")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cache.json")
+	testTokenStore(t, NewFileTokenStore(path))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// a new store on the same file sees the cached entries
+	token, err := NewFileTokenStore(path).GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token)
+}
+
+func TestNewAuth0ClientWithStore(t *testing.T) {
+	_, err := NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "audience", "https://auth", nil, nil, nil, "test")
+	assert.Error(t, err)
+
+	store := NewMemoryTokenStore()
+	client, err := NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "audience", "https://auth", nil, store, nil, "test")
+	assert.NoError(t, err)
+	_, err = client.GetToken()
+	assert.EqualError(t, err, "GetToken: could not find the associated token")
+}
+
+type textSender struct {
+	texts []string
+}
+
+func (s *textSender) SendText(text string) error {
+	s.texts = append(s.texts, text)
+	return nil
+}
+
+func TestClientProviderClose(t *testing.T) {
+	slack := &textSender{}
+	client, err := NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "audience", "https://auth", nil, NewMemoryTokenStore(), slack, "test")
+	assert.NoError(t, err)
+	assert.NotNil(t, client.dispatcher)
+	client.notify(alert.Error, "auth0 is down")
+	assert.NoError(t, client.Close(time.Second))
+	assert.Len(t, slack.texts, 1)
+
+	// the alerters of the caller are not closed
+	alerts := alert.NewRecorder()
+	client, err = NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "audience", "https://auth", nil, NewMemoryTokenStore(), alerts, "test")
+	assert.NoError(t, err)
+	assert.Nil(t, client.dispatcher)
+	assert.NoError(t, client.Close(time.Second))
+}
+
+var (
+	_ KeyedTokenStore = (*MemoryTokenStore)(nil)
+	_ KeyedTokenStore = (*FileTokenStore)(nil)
+	_ KeyedTokenStore = (*ESTokenStore)(nil)
+	_ KeyedTokenStore = (*SSMTokenStore)(nil)
+)
+
+func TestFileTokenStoreForKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth0")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := NewFileTokenStore(filepath.Join(dir, "cache.json"))
+	key := TokenKey{Audience: "orgs"}
+	keyed := store.ForKey(key)
+	assert.NoError(t, keyed.SetToken("orgs-token"))
+	_, err = store.GetToken()
+	assert.Equal(t, ErrCacheMiss, err)
+
+	token, err := NewFileTokenStore(filepath.Join(dir, "cache-"+key.ID()+".json")).GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "orgs-token", token)
+}
+
+// fakeTokenES keeps the documents written by ESTokenStore in memory,
+// updates of missing documents and creations of existing ones fail like in ES
+type fakeTokenES struct {
+	docs map[string]map[string]json.RawMessage
+}
+
+func newFakeTokenES() *fakeTokenES {
+	return &fakeTokenES{docs: make(map[string]map[string]json.RawMessage)}
+}
+
+func (f *fakeTokenES) put(index, id string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if f.docs[index] == nil {
+		f.docs[index] = make(map[string]json.RawMessage)
+	}
+	f.docs[index][id] = data
+	return nil
+}
+
+func (f *fakeTokenES) Search(index string, query map[string]interface{}) ([]byte, error) {
+	docs, ok := f.docs[index]
+	if !ok {
+		return nil, errors.New("index doesn't exist")
+	}
+	id := query["query"].(map[string]interface{})["term"].(map[string]interface{})["_id"].(string)
+	hits := make([]map[string]json.RawMessage, 0)
+	if doc, ok := docs[id]; ok {
+		hits = append(hits, map[string]json.RawMessage{"_source": doc})
+	}
+	return json.Marshal(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+}
+
+func (f *fakeTokenES) UpdateDocument(index string, id string, body interface{}) ([]byte, error) {
+	if _, ok := f.docs[index][id]; !ok {
+		return nil, errors.New("document missing")
+	}
+	return []byte(`{}`), f.put(index, id, body)
+}
+
+func (f *fakeTokenES) BulkInsert(data []elastic.BulkData) ([]byte, error) {
+	for _, item := range data {
+		if err := f.put(item.IndexName, item.ID, item.Data); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(`{}`), nil
+}
+
+func (f *fakeTokenES) CreateDocument(index, documentID string, body []byte) ([]byte, error) {
+	if _, ok := f.docs[index][documentID]; ok {
+		return nil, errors.New("version conflict, document already exists")
+	}
+	return []byte(`{}`), f.put(index, documentID, json.RawMessage(body))
+}
+
+func (f *fakeTokenES) CreateIndex(index string, body []byte) ([]byte, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeTokenES) Get(index string, query map[string]interface{}, result interface{}) error {
+	return errors.New("not implemented")
+}
+
+// fakeUpsertES adds the upserts to fakeTokenES
+type fakeUpsertES struct {
+	*fakeTokenES
+	upserts int
+}
+
+func (f *fakeUpsertES) UpsertDocument(index string, id string, body interface{}) ([]byte, error) {
+	f.upserts++
+	return []byte(`{}`), f.put(index, id, body)
+}
+
+func TestESTokenStore(t *testing.T) {
+	upsertES := &fakeUpsertES{fakeTokenES: newFakeTokenES()}
+	testESTokenStore(t, upsertES.fakeTokenES, upsertES)
+	assert.Equal(t, 4, upsertES.upserts)
+
+	// the clients without upserts create the missing documents
+	es := newFakeTokenES()
+	testESTokenStore(t, es, es)
+	assert.NoError(t, NewESTokenStore(es, "test").SetToken("new-token"))
+	token, err := NewESTokenStore(es, "test").GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", token)
+}
+
+func testESTokenStore(t *testing.T, es *fakeTokenES, client ESClientProvider) {
+	testTokenStore(t, NewESTokenStore(client, "test"))
+
+	// the documents of a new key do not exist yet
+	keyed := NewESTokenStore(client, "test").ForKey(TokenKey{Audience: "https://api.lfx.dev/orgs", Scopes: []string{"read:orgs"}})
+	_, err := keyed.GetToken()
+	assert.Equal(t, ErrCacheMiss, err)
+	assert.NoError(t, keyed.SetToken("orgs-token"))
+	assert.NoError(t, keyed.SetJwks("orgs-cert"))
+	token, err := keyed.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "orgs-token", token)
+	cert, err := keyed.GetJwks()
+	assert.NoError(t, err)
+	assert.Equal(t, "orgs-cert", cert)
+
+	token, err = NewESTokenStore(client, "test").GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token)
+	assert.Contains(t, es.docs[auth0TokenCache+"test"], "token")
+}
+
+// fakeSSM keeps the parameters in memory, creating an existing parameter or overwriting a missing one fails like in SSM
+type fakeSSM struct {
+	params map[string]string
+}
+
+func (f *fakeSSM) GetParameter(name string) (string, error) {
+	v, ok := f.params[name]
+	if !ok {
+		return "", &types.ParameterNotFound{}
+	}
+	return v, nil
+}
+
+func (f *fakeSSM) PutParameter(name string, value string, overwrite bool) error {
+	if _, ok := f.params[name]; ok != overwrite {
+		return errors.New("parameter already exists or is missing")
+	}
+	f.params[name] = value
+	return nil
+}
+
+func TestSSMTokenStore(t *testing.T) {
+	ssm := &fakeSSM{params: make(map[string]string)}
+	store := NewSSMTokenStoreWithClient(ssm, "/auth0-cache", "test")
+	testTokenStore(t, store)
+	assert.NoError(t, store.SetToken("new-token"))
+	assert.Equal(t, "new-token", ssm.params["/auth0-cache/test/token"])
+
+	key := TokenKey{Audience: "orgs"}
+	keyed := store.ForKey(key)
+	_, err := keyed.GetToken()
+	assert.Equal(t, ErrCacheMiss, err)
+	assert.NoError(t, keyed.SetToken("orgs-token"))
+	assert.Equal(t, "orgs-token", ssm.params["/auth0-cache/test/"+key.ID()+"/token"])
+
+	store = NewSSMTokenStoreWithClient(&failingSSM{}, "/auth0-cache", "test")
+	_, err = store.GetToken()
+	assert.EqualError(t, err, "ssm is down")
+}
+
+type failingSSM struct{}
+
+func (f *failingSSM) GetParameter(name string) (string, error) {
+	return "", errors.New("ssm is down")
+}
+
+func (f *failingSSM) PutParameter(name string, value string, overwrite bool) error {
+	return errors.New("ssm is down")
+}
+
+// fakeLockES adds the lease operations to fakeTokenES
+type fakeLockES struct {
+	*fakeTokenES
+}
+
+func (f *fakeLockES) CreateDocumentAndWait(index, documentID string, body []byte) ([]byte, error) {
+	return []byte(`{}`), nil
+}
+
+func (f *fakeLockES) DeleteDocumentByQuery(index string, query map[string]interface{}) ([]byte, error) {
+	return []byte(`{"deleted":1}`), nil
+}
+
+func TestMultiClientESLocker(t *testing.T) {
+	// an ES client without the lease operations falls back to a lock in memory
+	multi, err := NewMultiClient("test", Tenant{}, nil, NewESTokenStore(newFakeTokenES(), "test"), nil, "test")
+	assert.NoError(t, err)
+	_, ok := multi.locker.(*lock.MemoryLocker)
+	assert.True(t, ok)
+
+	multi, err = NewMultiClient("test", Tenant{}, nil, NewESTokenStore(&fakeLockES{newFakeTokenES()}, "test"), nil, "test")
+	assert.NoError(t, err)
+	_, ok = multi.locker.(*lock.ESLocker)
+	assert.True(t, ok)
+
+	client, err := NewAuth0Client("test", "client_credentials", "id", "secret", "audience", "https://auth", nil, newFakeTokenES(), nil, "test")
+	assert.NoError(t, err)
+	_, ok = client.locker.(*lock.MemoryLocker)
+	assert.True(t, ok)
+	client, err = NewAuth0Client("test", "client_credentials", "id", "secret", "audience", "https://auth", nil, &fakeLockES{newFakeTokenES()}, nil, "test")
+	assert.NoError(t, err)
+	_, ok = client.locker.(*lock.ESLocker)
+	assert.True(t, ok)
+}
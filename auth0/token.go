@@ -1,16 +1,18 @@
 package auth0
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"os"
 	"time"
 
+	"github.com/LF-Engineering/dev-analytics-libraries/alert"
 	"github.com/LF-Engineering/dev-analytics-libraries/elastic"
+	"github.com/LF-Engineering/dev-analytics-libraries/lock"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
 // HTTPClientProvider used in connecting to remote http server
@@ -28,23 +30,39 @@ type ESClientProvider interface {
 	BulkInsert(data []elastic.BulkData) ([]byte, error)
 }
 
-// SlackProvider ...
+// ESUpsertProvider is an ESClientProvider creating the documents it updates when missing, like elastic.ClientProvider.
+// Without it, ESTokenStore creates the documents whose update fails.
+type ESUpsertProvider interface {
+	UpsertDocument(index string, id string, body interface{}) ([]byte, error)
+}
+
+// SlackProvider receives alerts, a slack.Provider or any alert.Alerter
 type SlackProvider interface {
 	SendText(text string) error
 }
 
+// alertsCloseTimeout bounds the wait for the pending alerts of a replaced dispatcher
+const alertsCloseTimeout = 5 * time.Second
+
 // ClientProvider ...
 type ClientProvider struct {
 	AuthGrantType    string
 	AuthClientID     string
 	AuthClientSecret string
 	AuthAudience     string
+	AuthScope        string
 	AuthURL          string
 	Environment      string
+	cacheKey         string
+	provider         Provider
 	httpClient       HTTPClientProvider
-	esClient         ESClientProvider
-	slackClient      SlackProvider
-	appName          string
+	store            TokenStore
+	locker           lock.Locker
+	limiter          *rateLimiter
+	alerter          alert.Alerter
+	// dispatcher is the alerter created for the slack client, stopped by Close
+	dispatcher *alert.Dispatcher
+	appName    string
 }
 
 // NewAuth0Client ...
@@ -58,6 +76,42 @@ func NewAuth0Client(env,
 	esClient ESClientProvider,
 	slackClient SlackProvider,
 	appName string) (*ClientProvider, error) {
+	auth0, err := NewAuth0ClientWithStore(env,
+		authGrantType,
+		authClientID,
+		authClientSecret,
+		authAudience,
+		authURL,
+		httpClient,
+		NewESTokenStore(esClient, env),
+		slackClient,
+		appName)
+	if err != nil {
+		return nil, err
+	}
+
+	// share the token generation lock between all instances using the same ES cluster
+	auth0.locker = esLocker("NewAuth0Client", esClient, env, appName)
+
+	return auth0, nil
+}
+
+// NewAuth0ClientWithStore creates a client caching the token, the jwks and the last request date in store.
+// slackClient may be nil when no alerting is wanted, a plain slack client is wrapped in an alert.Dispatcher
+// stopped by Close.
+func NewAuth0ClientWithStore(env,
+	authGrantType,
+	authClientID,
+	authClientSecret,
+	authAudience,
+	authURL string,
+	httpClient HTTPClientProvider,
+	store TokenStore,
+	slackClient SlackProvider,
+	appName string) (*ClientProvider, error) {
+	if store == nil {
+		return nil, errors.New("NewAuth0ClientWithStore: token store is nil")
+	}
 	auth0 := &ClientProvider{
 		AuthGrantType:    authGrantType,
 		AuthClientID:     authClientID,
@@ -65,15 +119,49 @@ func NewAuth0Client(env,
 		AuthAudience:     authAudience,
 		AuthURL:          authURL,
 		Environment:      env,
+		provider:         Auth0Provider(authURL),
 		httpClient:       httpClient,
-		esClient:         esClient,
-		slackClient:      slackClient,
+		store:            store,
+		locker:           lock.NewMemoryLocker(lockOwner(appName)),
+		limiter:          newRateLimiter(DefaultRatePolicy),
+		alerter:          alert.Wrap(slackClient),
 		appName:          appName,
 	}
+	auth0.dispatcher = ownDispatcher(slackClient, auth0.alerter)
 
 	return auth0, nil
 }
 
+// SetLocker replaces the lock guarding token generation, e.g. to share it between instances
+func (a *ClientProvider) SetLocker(locker lock.Locker) {
+	a.locker = locker
+}
+
+// lockName is the token generation lock of the environment, one per token key for multi audience clients
+func (a *ClientProvider) lockName() string {
+	if a.cacheKey == "" {
+		return auth0TokenLock + a.Environment
+	}
+	return auth0TokenLock + a.Environment + "-" + a.cacheKey
+}
+
+// lockOwner identifies this process as a lock owner
+// esLocker locks the token generation in ES when esClient supports the lease operations,
+// otherwise in memory only, the caller sharing a lock between instances with SetLocker
+func esLocker(operation string, esClient interface{}, env string, appName string) lock.Locker {
+	lockClient, ok := esClient.(lock.ESClientProvider)
+	if !ok {
+		log.Printf("%s: the ES client does not support the token lock, the token generation is only locked in this instance, use SetLocker to share a lock", operation)
+		return lock.NewMemoryLocker(lockOwner(appName))
+	}
+	return lock.NewESLocker(lockClient, auth0TokenLockIndex+env, lockOwner(appName))
+}
+
+func lockOwner(appName string) string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%s-%d-%s", appName, host, os.Getpid(), uuid.New().String())
+}
+
 // GetToken ...
 func (a *ClientProvider) GetToken() (string, error) {
 	authToken, err := a.getCachedToken()
@@ -87,134 +175,140 @@ func (a *ClientProvider) GetToken() (string, error) {
 	}
 
 	// check token validity
-	ok, _, err := a.isValid(authToken, false)
-	if ok && err == nil {
-		return authToken, nil
+	token, _, err := a.verify(authToken, false)
+	if err == nil {
+		return token, nil
 	}
 
 	return authToken, errors.New("cached token is not valid")
 }
 
 func (a *ClientProvider) generateToken() (string, error) {
-	var result Resp
-	d, err := a.getLastActionDate()
+	// only one instance at a time may check the last request date and request a token
+	lease, err := a.locker.Acquire(a.lockName(), tokenLockTTL)
+	if err == lock.ErrLocked {
+		return "", errors.New("a token is being generated by another instance")
+	}
 	if err != nil {
 		return "", err
 	}
+	defer func() {
+		if err := a.locker.Release(lease); err != nil {
+			log.Println("generateToken: could not release the token lock: ", err)
+		}
+	}()
 
-	payload := map[string]string{
-		"grant_type":    a.AuthGrantType,
-		"client_id":     a.AuthClientID,
-		"client_secret": a.AuthClientSecret,
-		"audience":      a.AuthAudience,
-	}
-
-	body, err := json.Marshal(payload)
+	d, err := a.getLastActionDate()
 	if err != nil {
-		log.Println("generateToken : unmarshal payload error :", err)
-	}
-
-	// prevent new call if the last call issued since less than one hour
-	if d.Add(1 * time.Hour).After(time.Now().UTC()) {
-		return "", errors.New("can not request more than one token within the same hour")
+		return "", err
 	}
 
-	_, response, err := a.httpClient.Request(fmt.Sprintf("%s/oauth/token", a.AuthURL), "POST", nil, body, nil)
+	// prevent new calls beyond the rate policy and while backing off after failures
+	now := time.Now().UTC()
+	state, err := a.limiter.allow(d, now)
 	if err != nil {
-		go func() {
-			errMsg := fmt.Sprintf("%s-%s: error generating a new token\n %s", a.appName, a.Environment, err)
-			if err := a.slackClient.SendText(errMsg); err != nil {
-				log.Println(" Err: GenerateToken ", a.Environment, err)
-			}
-
-		}()
-		log.Println("Err: GenerateToken ", err)
+		return "", err
 	}
 
-	go func() {
-		err = a.createLastActionDate()
-		log.Println(err)
-	}()
-
-	err = json.Unmarshal(response, &result)
+	result, err := a.requestToken()
 	if err != nil {
-		log.Println("GenerateToken", err)
+		a.notify(alert.Error, fmt.Sprintf("%s-%s: error generating a new token\n %s", a.appName, a.Environment, err))
+		log.Println("Err: GenerateToken ", err)
+		a.limiter.failure(now, err)
+		return "", err
 	}
 	if result.AccessToken != "" {
 		log.Println("GenerateToken: Token generated successfully.")
 	}
 
-	ok, _, err := a.isValid(result.AccessToken, true)
-	if !ok || err != nil {
-		go func() {
-			errMsg := fmt.Sprintf("%s-%s: error validating the newly created token\n %s", a.appName, a.Environment, err)
-			err := a.slackClient.SendText(errMsg)
-			fmt.Println("Err: send to slack: ", err)
-		}()
+	entry, err := a.cacheEntry(result)
+	if err == nil {
+		_, _, err = a.verify(entry, true)
+	}
+	if err != nil {
+		a.notify(alert.Critical, fmt.Sprintf("%s-%s: error validating the newly created token\n %s", a.appName, a.Environment, err))
+		a.limiter.failure(now, err)
 		return "", errors.New("created token is not valid")
 	}
 
-	return result.AccessToken, nil
+	// only issued tokens count against the rate policy
+	if err := a.createLastActionDate(state); err != nil {
+		log.Println("generateToken: could not save the last request date: ", err)
+	}
+	a.limiter.success(now, state)
+
+	return entry, nil
+}
+
+// SetRatePolicy replaces DefaultRatePolicy for the token requests of this client
+func (a *ClientProvider) SetRatePolicy(policy RatePolicy) {
+	a.limiter.setPolicy(policy)
+}
+
+// Health reports the last token requests outcome and when the next request is allowed
+func (a *ClientProvider) Health() AuthHealth {
+	return a.limiter.status()
 }
 
 func (a *ClientProvider) getCachedToken() (string, error) {
-	res, err := a.esClient.Search(strings.TrimSpace(auth0TokenCache+a.Environment), searchTokenQuery)
-	if err != nil {
-		go func() {
-			errMsg := fmt.Sprintf("%s-%s: error cached token not found\n %s", a.appName, a.Environment, err)
-			err := a.slackClient.SendText(errMsg)
-			fmt.Println("Err: send to slack: ", err)
-		}()
-		return "", err
+	token, err := a.store.GetToken()
+	if err == ErrCacheMiss {
+		return "", errors.New("GetToken: could not find the associated token")
 	}
-
-	var e ESTokenSchema
-	err = json.Unmarshal(res, &e)
 	if err != nil {
-		log.Println("repository: GetOauthToken: could not unmarshal the data", err)
+		a.notify(alert.Warning, fmt.Sprintf("%s-%s: error cached token not found\n %s", a.appName, a.Environment, err))
 		return "", err
 	}
 
-	if len(e.Hits.Hits) > 0 {
-		data := e.Hits.Hits[0]
-		return data.Source.Token, nil
-	}
-
-	return "", errors.New("GetToken: could not find the associated token")
+	return token, nil
 }
 
 func (a *ClientProvider) createAuthToken(token string) error {
-	log.Println("creating new auth token")
-	at := AuthToken{
-		Name:      "AuthToken",
-		Token:     token,
-		CreatedAt: time.Now().UTC(),
+	return a.store.SetToken(token)
+}
+
+// notify reports msg when an alerter is configured
+func (a *ClientProvider) notify(severity alert.Severity, msg string) {
+	if a.alerter == nil {
+		return
 	}
-	_, err := a.esClient.UpdateDocument(fmt.Sprintf("%s%s", auth0TokenCache, a.Environment), tokenDoc, at)
-	if err != nil {
-		log.Println("could not write the data")
-		return err
+	a.alerter.Alert(severity, msg)
+}
+
+// SetAlerter replaces the alerter, nil disables alerting
+func (a *ClientProvider) SetAlerter(alerter alert.Alerter) {
+	if a.dispatcher != nil {
+		if err := a.dispatcher.Close(alertsCloseTimeout); err != nil {
+			log.Println("SetAlerter: could not send the pending alerts: ", err)
+		}
+		a.dispatcher = nil
 	}
+	a.alerter = alerter
+}
 
-	return nil
+// FlushAlerts waits until the pending alerts are sent, call it on shutdown
+func (a *ClientProvider) FlushAlerts(timeout time.Duration) error {
+	if a.alerter == nil {
+		return nil
+	}
+	return a.alerter.Flush(timeout)
 }
 
-var searchTokenQuery = map[string]interface{}{
-	"size": 1,
-	"query": map[string]interface{}{
-		"term": map[string]interface{}{
-			"_id": tokenDoc,
-		},
-	},
+// Close sends the pending alerts and stops the alert dispatcher created for the slack client, if any
+func (a *ClientProvider) Close(timeout time.Duration) error {
+	if a.dispatcher == nil {
+		return nil
+	}
+	return a.dispatcher.Close(timeout)
 }
 
-var searchCacheQuery = map[string]interface{}{
-	"size": 1,
-	"query": map[string]interface{}{
-		"term": map[string]interface{}{
-			"_id": lastTokenDate,
-		},
-	},
+// ownDispatcher returns the dispatcher alert.Wrap created for slackClient, nil when slackClient is an alerter owned by the caller
+func ownDispatcher(slackClient SlackProvider, alerter alert.Alerter) *alert.Dispatcher {
+	if _, shared := slackClient.(alert.Alerter); shared {
+		return nil
+	}
+	dispatcher, _ := alerter.(*alert.Dispatcher)
+	return dispatcher
 }
 
 func (a *ClientProvider) isValid(token string, refreshJwks bool) (bool, jwt.MapClaims, error) {
@@ -248,53 +342,25 @@ func (a *ClientProvider) isValid(token string, refreshJwks bool) (bool, jwt.MapC
 	return p.Valid, claims, err
 }
 
-func (a *ClientProvider) createLastActionDate() error {
-	s := struct {
-		Date time.Time `json:"date"`
-	}{
-		Date: time.Now().UTC(),
-	}
-	bul := []elastic.BulkData{
-		{
-			IndexName: strings.TrimSpace(lastAuth0TokenRequest + a.Environment),
-			ID:        lastTokenDate,
-			Data:      s,
-		},
-	}
-	_, err := a.esClient.BulkInsert(bul)
-	if err != nil {
-		log.Println("could not write the data to elastic")
-		return err
-	}
-
-	return nil
+// createLastActionDate saves the rate limiter state, the last issuance date with the default policy
+func (a *ClientProvider) createLastActionDate(state time.Time) error {
+	return a.store.SetLastRequestDate(state)
 }
 
 func (a *ClientProvider) getLastActionDate() (time.Time, error) {
-	now := time.Now().UTC()
-	res, err := a.esClient.Search(strings.TrimSpace(lastAuth0TokenRequest+a.Environment), searchCacheQuery)
-	if err != nil && err.Error() == "index doesn't exist" {
-		return now.Add(-2 * time.Hour), nil
-	}
-	if err != nil {
-		return now, err
+	d, err := a.store.GetLastRequestDate()
+	if err == ErrCacheMiss {
+		// no token was ever requested, the rate limiter bucket is full
+		return time.Time{}, nil
 	}
-
-	var e LastActionSchema
-	err = json.Unmarshal(res, &e)
 	if err != nil {
-		log.Println("repository: getLastActionDate failed", err)
-		return now, err
+		return time.Now().UTC(), err
 	}
 
-	if len(e.Hits.Hits) > 0 {
-		data := e.Hits.Hits[0]
-		return data.Source.Date, nil
-	}
-
-	return now, errors.New("getLastActionDate: could not find the associated date")
+	return d, nil
 }
 
+// refreshCachedToken generates and caches a new token, it returns the cache entry
 func (a *ClientProvider) refreshCachedToken() (string, error) {
 	authToken, err := a.generateToken()
 	if err != nil {
@@ -320,13 +386,9 @@ func (a *ClientProvider) RefreshToken() (RefreshResult, error) {
 		return RefreshSuccessful, nil
 	}
 
-	ok, claims, err := a.isValid(authToken, false)
-	if ok && err == nil {
-		if !claims.VerifyExpiresAt(time.Now().Add(60*time.Minute).Unix(), false) {
-			if _, err := a.refreshCachedToken(); err != nil {
-				log.Printf("Error refresh auth0 token %s\n", err.Error())
-				return RefreshError, err
-			}
+	_, expiry, err := a.verify(authToken, false)
+	if err == nil {
+		if expiry.Before(time.Now().Add(a.refreshBefore())) {
 			if _, err := a.refreshCachedToken(); err != nil {
 				log.Printf("Error refresh auth0 token %s\n", err.Error())
 				return RefreshError, err
@@ -342,5 +404,5 @@ func (a *ClientProvider) RefreshToken() (RefreshResult, error) {
 		return RefreshError, err
 	}
 
-	return NotExpireSoon, nil
+	return RefreshSuccessful, nil
 }
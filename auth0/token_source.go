@@ -0,0 +1,199 @@
+package auth0
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultRefreshBefore is how long before expiry a token source renews its token
+	DefaultRefreshBefore = 60 * time.Minute
+	// DefaultRefreshInterval is how often the background renewal checks the token
+	DefaultRefreshInterval = 5 * time.Minute
+	// DefaultRenewRetry is how long a token source keeps a token expiring soon before trying to renew it again
+	DefaultRenewRetry = time.Minute
+)
+
+// TokenSource returns a valid auth0 token, renewing it transparently before it expires.
+// Concurrent callers share a single renewal. It implements oauth2.TokenSource and the
+// GetToken method used by the affiliation, orgs and users clients.
+type TokenSource struct {
+	refreshBefore time.Duration
+	renewRetry    time.Duration
+	fetch         func() (string, time.Time, error)
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	renewAt  time.Time
+	inflight *tokenCall
+}
+
+// tokenCall is a renewal shared by all callers waiting on it
+type tokenCall struct {
+	done   chan struct{}
+	token  string
+	expiry time.Time
+	err    error
+}
+
+// NewTokenSource creates a token source renewing the token refreshBefore its expiry,
+// the provider RefreshBefore or DefaultRefreshBefore when zero
+func (a *ClientProvider) NewTokenSource(refreshBefore time.Duration) *TokenSource {
+	if refreshBefore <= 0 {
+		refreshBefore = a.refreshBefore()
+	}
+	return newTokenSource(refreshBefore, func() (string, time.Time, error) {
+		return a.fetchToken(refreshBefore)
+	})
+}
+
+func newTokenSource(refreshBefore time.Duration, fetch func() (string, time.Time, error)) *TokenSource {
+	return &TokenSource{
+		refreshBefore: refreshBefore,
+		renewRetry:    DefaultRenewRetry,
+		fetch:         fetch,
+	}
+}
+
+// GetToken returns the current token, renewing it when it expires soon
+func (s *TokenSource) GetToken() (string, error) {
+	token, _, err := s.get()
+	return token, err
+}
+
+// Token implements oauth2.TokenSource
+func (s *TokenSource) Token() (*oauth2.Token, error) {
+	token, expiry, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// Start renews the token in the background every interval (DefaultRefreshInterval when zero), call stop to end it
+func (s *TokenSource) Start(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, _, err := s.get(); err != nil {
+				log.Println("TokenSource: background renewal failed: ", err)
+			}
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-finished
+		})
+	}
+}
+
+func (s *TokenSource) get() (string, time.Time, error) {
+	s.mu.Lock()
+	if now := time.Now(); s.token != "" && now.Before(s.renewAt) && now.Before(s.expiry) {
+		token, expiry := s.token, s.expiry
+		s.mu.Unlock()
+		return token, expiry, nil
+	}
+
+	call := s.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.inflight = call
+		go s.renew(call)
+	}
+	s.mu.Unlock()
+
+	<-call.done
+	if call.err != nil {
+		// keep serving the current token while it is still valid
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.token != "" && time.Now().Before(s.expiry) {
+			return s.token, s.expiry, nil
+		}
+		return "", time.Time{}, call.err
+	}
+
+	return call.token, call.expiry, nil
+}
+
+func (s *TokenSource) renew(call *tokenCall) {
+	call.token, call.expiry, call.err = s.fetch()
+
+	s.mu.Lock()
+	now := time.Now()
+	if call.err == nil {
+		s.token, s.expiry = call.token, call.expiry
+		s.renewAt = call.expiry.Add(-s.refreshBefore)
+	}
+	// a token already expiring soon, renewed or kept after a failure, is served until the next retry
+	if s.token != "" && !s.renewAt.After(now) {
+		s.renewAt = now.Add(s.renewRetry)
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+
+	close(call.done)
+}
+
+// fetchToken returns the cached token when it does not expire within refreshBefore, otherwise generates and caches a new one
+func (a *ClientProvider) fetchToken(refreshBefore time.Duration) (string, time.Time, error) {
+	var cached string
+	var cachedExpiry time.Time
+	if entry, err := a.getCachedToken(); err == nil && entry != "" {
+		if token, expiry, err := a.verify(entry, false); err == nil {
+			if expiry.After(time.Now().Add(refreshBefore)) {
+				return token, expiry, nil
+			}
+			cached, cachedExpiry = token, expiry
+		}
+	}
+
+	entry, err := a.refreshCachedToken()
+	if err != nil {
+		// the cached token expires soon but can still be used
+		if cached != "" {
+			log.Println("TokenSource: could not renew the token, using the cached one: ", err)
+			return cached, cachedExpiry, nil
+		}
+		return "", time.Time{}, err
+	}
+
+	return a.verify(entry, false)
+}
+
+func tokenExpiry(claims jwt.MapClaims) time.Time {
+	switch exp := claims["exp"].(type) {
+	case float64:
+		return time.Unix(int64(exp), 0)
+	case int64:
+		return time.Unix(exp, 0)
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,90 @@
+package auth0
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenSourceSingleFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	source := newTokenSource(time.Minute, func() (string, time.Time, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "token", time.Now().Add(time.Hour), nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := source.GetToken()
+			assert.NoError(t, err)
+			assert.Equal(t, "token", token)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// cached token does not expire soon, no new renewal
+	token, err := source.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token.AccessToken)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestTokenSourceRenewal(t *testing.T) {
+	var calls int32
+	source := newTokenSource(time.Hour, func() (string, time.Time, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			return "", time.Time{}, errors.New("auth0 is down")
+		}
+		return "token", time.Now().Add(30 * time.Minute), nil
+	})
+	source.renewRetry = 5 * time.Millisecond
+
+	stop := source.Start(10 * time.Millisecond)
+	time.Sleep(55 * time.Millisecond)
+	stop()
+	stop()
+	assert.True(t, atomic.LoadInt32(&calls) > 1)
+
+	// renewal fails but the current token is still valid
+	token, err := source.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token)
+}
+
+func TestTokenSourceCachesTokenExpiringSoon(t *testing.T) {
+	var calls int32
+	source := newTokenSource(time.Hour, func() (string, time.Time, error) {
+		atomic.AddInt32(&calls, 1)
+		// the provider issues tokens shorter lived than the refresh window
+		return "token", time.Now().Add(30 * time.Minute), nil
+	})
+
+	for i := 0; i < 5; i++ {
+		token, err := source.GetToken()
+		assert.NoError(t, err)
+		assert.Equal(t, "token", token)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// renewed again once the retry delay is over
+	source.renewRetry = time.Millisecond
+	source.mu.Lock()
+	source.renewAt = time.Now()
+	source.mu.Unlock()
+	_, err := source.GetToken()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
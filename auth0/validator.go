@@ -0,0 +1,312 @@
+package auth0
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	// ErrMissingToken is returned when the request has no bearer token
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrUnknownKey is returned when the token kid is not in the jwks, even after refetching it
+	ErrUnknownKey = errors.New("unknown token signing key")
+)
+
+const (
+	// DefaultJwksMinRefresh is the minimum interval between two jwks fetches triggered by unknown kids
+	DefaultJwksMinRefresh = time.Minute
+)
+
+// ValidatorConfig contains the checks done on received tokens.
+// Claims values must be equal to the token claims, ClaimsCheck can do any other check.
+type ValidatorConfig struct {
+	JwksURL        string
+	Issuer         string
+	Audience       []string
+	RequiredScopes []string
+	Claims         map[string]interface{}
+	ClaimsCheck    func(claims jwt.MapClaims) error
+	Leeway         time.Duration
+	MinRefresh     time.Duration
+}
+
+// Validator checks tokens received by a service against a cached jwks, handling signing keys rotation
+type Validator struct {
+	config     ValidatorConfig
+	httpClient HTTPClientProvider
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	lastFetch time.Time
+	inflight  *jwksCall
+}
+
+// jwksCall is a jwks fetch shared by all the validations waiting on it
+type jwksCall struct {
+	done chan struct{}
+	err  error
+}
+
+type claimsContextKey struct{}
+
+// NewValidator creates a validator fetching the jwks from config.JwksURL, e.g. https://<tenant>/.well-known/jwks.json
+func NewValidator(config ValidatorConfig, httpClient HTTPClientProvider) (*Validator, error) {
+	if config.JwksURL == "" {
+		return nil, errors.New("NewValidator: jwks url is empty")
+	}
+	if httpClient == nil {
+		return nil, errors.New("NewValidator: http client is nil")
+	}
+	if config.MinRefresh <= 0 {
+		config.MinRefresh = DefaultJwksMinRefresh
+	}
+
+	return &Validator{
+		config:     config,
+		httpClient: httpClient,
+		keys:       make(map[string]*rsa.PublicKey),
+	}, nil
+}
+
+// Validate verifies the token signature, expiry, issuer, audience, scopes and claims and returns its claims
+func (v *Validator) Validate(token string) (jwt.MapClaims, error) {
+	parser := &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512"}, SkipClaimsValidation: true}
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = v.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// Middleware rejects requests without a valid bearer token with 401 and puts the verified claims in the request context.
+// The reason of a rejection is logged, not sent to the client.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			http.Error(w, ErrMissingToken.Error(), http.StatusUnauthorized)
+			return
+		}
+		claims, err := v.Validate(token)
+		if err != nil {
+			log.Println("Middleware: invalid token: ", err)
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+	})
+}
+
+// ClaimsFromContext returns the claims put in the request context by Validator.Middleware
+func ClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(jwt.MapClaims)
+	return claims, ok
+}
+
+func (v *Validator) verifyClaims(claims jwt.MapClaims) error {
+	now := time.Now()
+	if exp, ok := numericClaim(claims, "exp"); !ok || !now.Add(-v.config.Leeway).Before(exp) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(v.config.Leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	if iat, ok := numericClaim(claims, "iat"); ok && now.Add(v.config.Leeway).Before(iat) {
+		return errors.New("token used before issued")
+	}
+
+	if v.config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+			return fmt.Errorf("invalid issuer %q", iss)
+		}
+	}
+
+	if len(v.config.Audience) > 0 {
+		audiences := stringsClaim(claims, "aud")
+		if !intersects(audiences, v.config.Audience) {
+			return fmt.Errorf("invalid audience %v", audiences)
+		}
+	}
+
+	if len(v.config.RequiredScopes) > 0 {
+		// a missing scope claim is no scopes
+		scope, _ := claims["scope"].(string)
+		scopes := append(strings.Fields(scope), stringsClaim(claims, "permissions")...)
+		for _, required := range v.config.RequiredScopes {
+			if !contains(scopes, required) {
+				return fmt.Errorf("missing scope %q", required)
+			}
+		}
+	}
+
+	for name, expected := range v.config.Claims {
+		if fmt.Sprint(claims[name]) != fmt.Sprint(expected) {
+			return fmt.Errorf("invalid claim %q", name)
+		}
+	}
+
+	if v.config.ClaimsCheck != nil {
+		return v.config.ClaimsCheck(claims)
+	}
+
+	return nil
+}
+
+// key returns the public key kid, refetching the jwks at most once per MinRefresh when kid is unknown.
+// The jwks is fetched without holding the lock, concurrent validations wait for the same fetch.
+// A failed fetch does not count, the next validation fetches the jwks again.
+func (v *Validator) key(kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	if key, ok := v.keys[kid]; ok {
+		v.mu.Unlock()
+		return key, nil
+	}
+
+	call := v.inflight
+	if call == nil {
+		if !v.lastFetch.IsZero() && time.Since(v.lastFetch) < v.config.MinRefresh {
+			v.mu.Unlock()
+			return nil, ErrUnknownKey
+		}
+		call = &jwksCall{done: make(chan struct{})}
+		v.inflight = call
+		go v.fetch(call)
+	}
+	v.mu.Unlock()
+
+	<-call.done
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+func (v *Validator) fetch(call *jwksCall) {
+	keys, err := fetchJwks(v.httpClient, v.config.JwksURL)
+
+	v.mu.Lock()
+	if err == nil {
+		v.keys = keys
+		v.lastFetch = time.Now()
+	}
+	call.err = err
+	v.inflight = nil
+	v.mu.Unlock()
+
+	close(call.done)
+}
+
+// fetchJwks returns the RSA keys of a jwks by kid
+func fetchJwks(httpClient HTTPClientProvider, url string) (map[string]*rsa.PublicKey, error) {
+	statusCode, resp, err := httpClient.Request(url, "GET", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch jwks: status %d", statusCode)
+	}
+
+	var jwks Jwks
+	if err := json.Unmarshal(resp, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+
+	return ""
+}
+
+func numericClaim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	switch v := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		n, err := v.Int64()
+		return time.Unix(n, 0), err == nil
+	}
+
+	return time.Time{}, false
+}
+
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+func intersects(a, b []string) bool {
+	for _, v := range a {
+		if contains(b, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,285 @@
+package auth0
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeJwksServer serves the public keys of keys as a jwks with n and e parameters
+type fakeJwksServer struct {
+	keys     map[string]*rsa.PrivateKey
+	requests int
+}
+
+func (f *fakeJwksServer) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+	f.requests++
+	jwks := Jwks{}
+	for kid, key := range f.keys {
+		jwks.Keys = append(jwks.Keys, JSONWebKeys{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	resp, err := json.Marshal(jwks)
+	return http.StatusOK, resp, err
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	return signed
+}
+
+func newTestValidator(t *testing.T, config ValidatorConfig) (*Validator, *fakeJwksServer, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	server := &fakeJwksServer{keys: map[string]*rsa.PrivateKey{"key1": key}}
+
+	config.JwksURL = "https://auth/.well-known/jwks.json"
+	v, err := NewValidator(config, server)
+	assert.NoError(t, err)
+	return v, server, key
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   "https://auth/",
+		"aud":   []string{"api", "other"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"scope": "read:orgs write:orgs",
+		"azp":   "client",
+	}
+}
+
+func TestValidatorValidate(t *testing.T) {
+	config := ValidatorConfig{
+		Issuer:         "https://auth/",
+		Audience:       []string{"api"},
+		RequiredScopes: []string{"read:orgs"},
+		Claims:         map[string]interface{}{"azp": "client"},
+	}
+
+	cases := []struct {
+		name   string
+		update func(claims jwt.MapClaims)
+		err    string
+	}{
+		{"valid", func(claims jwt.MapClaims) {}, ""},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }, "token is expired"},
+		{"no expiry", func(claims jwt.MapClaims) { delete(claims, "exp") }, "token is expired"},
+		{"wrong issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://other/" }, `invalid issuer "https://other/"`},
+		{"single audience", func(claims jwt.MapClaims) { claims["aud"] = "api" }, ""},
+		{"wrong audience", func(claims jwt.MapClaims) { claims["aud"] = "other" }, "invalid audience [other]"},
+		{"missing scope", func(claims jwt.MapClaims) { claims["scope"] = "write:orgs" }, `missing scope "read:orgs"`},
+		{"scope from permissions", func(claims jwt.MapClaims) {
+			delete(claims, "scope")
+			claims["permissions"] = []string{"read:orgs"}
+		}, ""},
+		{"no scope claim", func(claims jwt.MapClaims) { delete(claims, "scope") }, `missing scope "read:orgs"`},
+		{"wrong claim", func(claims jwt.MapClaims) { claims["azp"] = "other" }, `invalid claim "azp"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v, _, key := newTestValidator(t, config)
+			claims := validClaims()
+			c.update(claims)
+
+			got, err := v.Validate(signToken(t, key, "key1", claims))
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "client", got["azp"])
+		})
+	}
+}
+
+func TestValidatorLeewayAndClaimsCheck(t *testing.T) {
+	v, _, key := newTestValidator(t, ValidatorConfig{
+		Leeway: time.Minute,
+		ClaimsCheck: func(claims jwt.MapClaims) error {
+			if claims["azp"] != "client" {
+				return errors.New("unexpected azp")
+			}
+			return nil
+		},
+	})
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+	_, err := v.Validate(signToken(t, key, "key1", claims))
+	assert.NoError(t, err)
+
+	claims["azp"] = "other"
+	_, err = v.Validate(signToken(t, key, "key1", claims))
+	assert.EqualError(t, err, "unexpected azp")
+}
+
+func TestValidatorKeyRotation(t *testing.T) {
+	v, server, key := newTestValidator(t, ValidatorConfig{MinRefresh: time.Hour})
+
+	_, err := v.Validate(signToken(t, key, "key1", validClaims()))
+	assert.NoError(t, err)
+	_, err = v.Validate(signToken(t, key, "key1", validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, server.requests)
+
+	// a new key is not refetched before MinRefresh
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	server.keys["key2"] = rotated
+	_, err = v.Validate(signToken(t, rotated, "key2", validClaims()))
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.requests)
+
+	v.lastFetch = time.Now().Add(-2 * time.Hour)
+	_, err = v.Validate(signToken(t, rotated, "key2", validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.requests)
+
+	// a token signed by a key that is not the one of its kid
+	_, err = v.Validate(signToken(t, rotated, "key1", validClaims()))
+	assert.Error(t, err)
+}
+
+// slowJwksServer blocks the jwks requests until release is closed
+type slowJwksServer struct {
+	*fakeJwksServer
+	mu      sync.Mutex
+	release chan struct{}
+}
+
+func (s *slowJwksServer) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fakeJwksServer.Request(url, method, header, body, params)
+}
+
+func TestValidatorFetchOutsideLock(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	server := &slowJwksServer{fakeJwksServer: &fakeJwksServer{keys: map[string]*rsa.PrivateKey{"key1": key}}, release: make(chan struct{})}
+	v, err := NewValidator(ValidatorConfig{JwksURL: "https://auth/.well-known/jwks.json"}, server)
+	assert.NoError(t, err)
+	v.keys, _ = fetchJwks(server.fakeJwksServer, v.config.JwksURL)
+	server.keys["key2"] = rotated
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := v.Validate(signToken(t, rotated, "key2", validClaims()))
+			assert.NoError(t, err)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// the known keys are validated while the jwks is being fetched
+	_, err = v.Validate(signToken(t, key, "key1", validClaims()))
+	assert.NoError(t, err)
+
+	close(server.release)
+	wg.Wait()
+	// one fetch for the initial keys, one shared by the unknown kid validations
+	assert.Equal(t, 2, server.requests)
+}
+
+func TestValidatorMiddleware(t *testing.T) {
+	v, _, key := newTestValidator(t, ValidatorConfig{Audience: []string{"api"}})
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		assert.True(t, ok)
+		_, _ = w.Write([]byte(claims["azp"].(string)))
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer invalid", http.StatusUnauthorized},
+		{"valid token", "Bearer " + signToken(t, key, "key1", validClaims()), http.StatusOK},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/orgs", nil)
+			if c.header != "" {
+				req.Header.Set("Authorization", c.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, c.status, rec.Code)
+			if c.status == http.StatusOK {
+				assert.Equal(t, "client", rec.Body.String())
+			}
+			// the validation errors are not sent to the client
+			assert.NotContains(t, rec.Body.String(), "segment")
+		})
+	}
+}
+
+func TestJSONWebKeysPEM(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwk := JSONWebKeys{
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+
+	cert, err := jwk.PEM()
+	assert.NoError(t, err)
+	parsed, err := jwt.ParseRSAPublicKeyFromPEM([]byte(cert))
+	assert.NoError(t, err)
+	assert.Equal(t, key.PublicKey.N, parsed.N)
+	assert.Equal(t, key.PublicKey.E, parsed.E)
+
+	_, err = JSONWebKeys{Kty: "EC"}.PEM()
+	assert.Error(t, err)
+}
+
+func TestGetPemCertUnknownKid(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	store := NewMemoryTokenStore()
+	assert.NoError(t, store.SetJwks("cached-cert"))
+	client, err := NewAuth0ClientWithStore("test", "client_credentials", "id", "secret", "audience", "https://auth", &fakeJwksServer{keys: map[string]*rsa.PrivateKey{"key1": key}}, store, nil, "test")
+	assert.NoError(t, err)
+
+	cert, err := client.getPemCert(&jwt.Token{Header: map[string]interface{}{"kid": "key2"}}, true)
+	assert.EqualError(t, err, "unable to find appropriate key")
+	assert.Equal(t, "", cert)
+
+	cert, err = client.getPemCert(&jwt.Token{Header: map[string]interface{}{"kid": "key1"}}, true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "cached-cert", cert)
+	cached, err := store.GetJwks()
+	assert.NoError(t, err)
+	assert.Equal(t, cert, cached)
+}
@@ -576,6 +576,15 @@ func (p *ClientProvider) SearchWithNoIndex(query map[string]interface{}) ([]byte
 
 // CreateDocument ...
 func (p *ClientProvider) CreateDocument(index, documentID string, body []byte) ([]byte, error) {
+	return p.createDocument(index, documentID, body, "")
+}
+
+// CreateDocumentAndWait creates a document and waits until it is visible to the searches (refresh=wait_for)
+func (p *ClientProvider) CreateDocumentAndWait(index, documentID string, body []byte) ([]byte, error) {
+	return p.createDocument(index, documentID, body, "wait_for")
+}
+
+func (p *ClientProvider) createDocument(index, documentID string, body []byte, refresh string) ([]byte, error) {
 	buf := bytes.NewReader(body)
 
 	// Create es document request
@@ -583,6 +592,7 @@ func (p *ClientProvider) CreateDocument(index, documentID string, body []byte) (
 		Index:      index,
 		DocumentID: documentID,
 		Body:       buf,
+		Refresh:    refresh,
 	}.Do(context.Background(), p.client)
 	if err != nil {
 		return nil, err
@@ -702,9 +712,20 @@ func (p *ClientProvider) ReadWithScroll(index string, query map[string]interface
 
 // UpdateDocument update elastic single document
 func (p *ClientProvider) UpdateDocument(index string, id string, body interface{}) ([]byte, error) {
+	return p.updateDocument(index, id, body, false)
+}
+
+// UpsertDocument updates a single document, creating it with body when it does not exist
+func (p *ClientProvider) UpsertDocument(index string, id string, body interface{}) ([]byte, error) {
+	return p.updateDocument(index, id, body, true)
+}
 
+func (p *ClientProvider) updateDocument(index string, id string, body interface{}, upsert bool) ([]byte, error) {
 	m := make(map[string]interface{})
 	m["doc"] = body
+	if upsert {
+		m["doc_as_upsert"] = true
+	}
 	b, err := jsoniter.Marshal(m)
 	if err != nil {
 		return nil, err
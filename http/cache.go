@@ -0,0 +1,354 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxCacheEntry is the size of the largest response body cached, larger responses are streamed to the caller
+const DefaultMaxCacheEntry = 1 << 20
+
+// CacheBackend stores the cached responses by key
+type CacheBackend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+	// Stats fills the Entries, Bytes and Evictions of the stats
+	Stats() CacheStats
+}
+
+// CacheStats counts the cache activity. A hit is served without contacting the server,
+// a revalidation is a conditional request answered with 304 Not Modified, and a miss fetches the whole response.
+type CacheStats struct {
+	Hits        int64
+	Revalidated int64
+	Misses      int64
+	Stored      int64
+	Entries     int
+	Bytes       int64
+	Evictions   int64
+}
+
+// Cache is a private http cache of GET responses. It serves the responses while fresh according to Cache-Control
+// or Expires, then revalidates them with If-None-Match or If-Modified-Since when they have an ETag or a Last-Modified.
+// A successful request with another method invalidates the cached response of its url.
+// A cache can be shared by several ClientProviders: the responses to requests with an Authorization header
+// are only served to requests with the same header.
+type Cache struct {
+	backend  CacheBackend
+	now      func() time.Time
+	maxEntry int64
+
+	hits        int64
+	revalidated int64
+	misses      int64
+	stored      int64
+}
+
+// NewCache creates a cache storing the responses in backend, a MemoryCache or a DiskCache
+func NewCache(backend CacheBackend) *Cache {
+	return &Cache{backend: backend, now: time.Now, maxEntry: DefaultMaxCacheEntry}
+}
+
+// SetMaxEntrySize replaces DefaultMaxCacheEntry, the size of the largest response body cached
+func (c *Cache) SetMaxEntrySize(size int64) {
+	c.maxEntry = size
+}
+
+// Stats returns the cache activity since its creation
+func (c *Cache) Stats() CacheStats {
+	stats := c.backend.Stats()
+	stats.Hits = atomic.LoadInt64(&c.hits)
+	stats.Revalidated = atomic.LoadInt64(&c.revalidated)
+	stats.Misses = atomic.LoadInt64(&c.misses)
+	stats.Stored = atomic.LoadInt64(&c.stored)
+	return stats
+}
+
+// cachedResponse is the stored form of a response
+type cachedResponse struct {
+	StatusCode int               `json:"status_code"`
+	Header     http.Header       `json:"header"`
+	Body       []byte            `json:"body"`
+	Vary       map[string]string `json:"vary,omitempty"`
+	Stored     time.Time         `json:"stored"`
+}
+
+func (c *Cache) load(key string, req *http.Request) (*cachedResponse, bool) {
+	data, ok := c.backend.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	var entry cachedResponse
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Println("Cache: could not read a cached response: ", err)
+		c.backend.Delete(key)
+		return nil, false
+	}
+	for name, value := range entry.Vary {
+		if req.Header.Get(name) != value {
+			return nil, false
+		}
+	}
+
+	return &entry, true
+}
+
+func (c *Cache) store(key string, entry *cachedResponse) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("Cache: could not store a response: ", err)
+		return
+	}
+	c.backend.Set(key, data)
+	atomic.AddInt64(&c.stored, 1)
+}
+
+// fresh tells whether the entry can be served without revalidation
+func (e *cachedResponse) fresh(now time.Time, reqCC map[string]string) bool {
+	cc := cacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+
+	var lifetime time.Duration
+	if v, ok := cc["max-age"]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(seconds) * time.Second
+	} else if expires, err := http.ParseTime(e.Header.Get("Expires")); err == nil {
+		date, err := http.ParseTime(e.Header.Get("Date"))
+		if err != nil {
+			date = e.Stored
+		}
+		lifetime = expires.Sub(date)
+	}
+
+	age := now.Sub(e.Stored)
+	if seconds, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		age += time.Duration(seconds) * time.Second
+	}
+	if v, ok := reqCC["max-age"]; ok {
+		if seconds, err := strconv.Atoi(v); err != nil || age > time.Duration(seconds)*time.Second {
+			return false
+		}
+	}
+
+	return age < lifetime
+}
+
+func (e *cachedResponse) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	header.Set("X-From-Cache", "1")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// SetCache caches the responses of the provider in cache, nil disables the cache
+func (h *ClientProvider) SetCache(cache *Cache) {
+	h.cache = cache
+	h.buildTransport()
+}
+
+// cacheTransport is a RoundTripper serving the responses of a Cache
+type cacheTransport struct {
+	next  http.RoundTripper
+	cache *Cache
+}
+
+func newCacheTransport(next http.RoundTripper, cache *Cache) *cacheTransport {
+	return &cacheTransport{next: next, cache: cache}
+}
+
+// RoundTrip ...
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	key := cacheKey(req)
+	if req.Method != http.MethodGet {
+		res, err := next.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions && res.StatusCode < 400 {
+			t.cache.backend.Delete(key)
+			t.cache.backend.Delete(req.URL.String())
+		}
+		return res, err
+	}
+
+	reqCC := cacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		// the caller handles the caching of this request
+		return next.RoundTrip(req)
+	}
+
+	entry, ok := t.cache.load(key, req)
+	if ok && entry.fresh(t.cache.now(), reqCC) {
+		atomic.AddInt64(&t.cache.hits, 1)
+		return entry.response(req), nil
+	}
+
+	r := req
+	etag, modified := "", ""
+	if ok {
+		etag, modified = entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+	}
+	if etag != "" || modified != "" {
+		// a RoundTripper must not modify the caller's request
+		r = req.Clone(req.Context())
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		if modified != "" {
+			r.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	res, err := next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if r != req && res.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		_ = res.Body.Close()
+		atomic.AddInt64(&t.cache.revalidated, 1)
+		for name, values := range res.Header {
+			if name != "Content-Length" {
+				entry.Header[name] = values
+			}
+		}
+		entry.Stored = t.cache.now()
+		t.cache.store(key, entry)
+		return entry.response(req), nil
+	}
+
+	atomic.AddInt64(&t.cache.misses, 1)
+	if !storable(res) {
+		return res, nil
+	}
+
+	if res.ContentLength > t.cache.maxEntry {
+		return res, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, t.cache.maxEntry+1))
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > t.cache.maxEntry {
+		// too large to be cached, the caller reads what was buffered then the rest of the body
+		res.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), res.Body), Closer: res.Body}
+		return res, nil
+	}
+	_ = res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry = &cachedResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header.Clone(),
+		Body:       body,
+		Stored:     t.cache.now(),
+	}
+	for _, name := range varyHeaders(res.Header) {
+		if entry.Vary == nil {
+			entry.Vary = make(map[string]string)
+		}
+		entry.Vary[name] = req.Header.Get(name)
+	}
+	t.cache.store(key, entry)
+
+	return res, nil
+}
+
+// cacheKey is the url of the request, followed by a hash of its Authorization header when it has one
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		key += "#auth=" + hex.EncodeToString(sum[:16])
+	}
+	return key
+}
+
+// prefixedBody is a response body partly read ahead
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// storable accepts the successful responses that are either fresh for a while or can be revalidated
+func storable(res *http.Response) bool {
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNonAuthoritativeInfo && res.StatusCode != http.StatusMovedPermanently {
+		return false
+	}
+	cc := cacheControl(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	for _, name := range varyHeaders(res.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, maxAge := cc["max-age"]
+	return maxAge || res.Header.Get("Expires") != "" || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+}
+
+// cacheControl parses the Cache-Control directives, with lower case names
+func cacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, arg := directive, ""
+			if i := strings.Index(directive, "="); i >= 0 {
+				name, arg = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = arg
+		}
+	}
+
+	return cc
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
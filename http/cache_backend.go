@@ -0,0 +1,229 @@
+package http
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryCache is an in-memory CacheBackend evicting the least recently used entries beyond its size limit
+type MemoryCache struct {
+	lru *lru
+}
+
+// NewMemoryCache creates a memory backend holding up to maxBytes of keys and values, zero meaning no limit
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{lru: newLRU(maxBytes)}
+}
+
+// Get ...
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.lru.mu.Lock()
+	defer c.lru.mu.Unlock()
+	e, ok := c.lru.get(key)
+	if !ok {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Set ...
+func (c *MemoryCache) Set(key string, value []byte) {
+	c.lru.mu.Lock()
+	defer c.lru.mu.Unlock()
+	c.lru.add(key, value, int64(len(key)+len(value)))
+}
+
+// Delete ...
+func (c *MemoryCache) Delete(key string) {
+	c.lru.mu.Lock()
+	defer c.lru.mu.Unlock()
+	c.lru.remove(key)
+}
+
+// Stats ...
+func (c *MemoryCache) Stats() CacheStats {
+	return c.lru.stats()
+}
+
+// DiskCache is a CacheBackend storing one file per entry in a directory,
+// evicting the least recently used entries beyond its size limit
+type DiskCache struct {
+	dir string
+	lru *lru
+}
+
+// NewDiskCache creates a disk backend in dir holding up to maxBytes of files, zero meaning no limit.
+// The entries already in dir are kept, the least recently used first evicted.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &DiskCache{dir: dir, lru: newLRU(maxBytes)}
+	c.lru.onEvict = c.removeFile
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, f := range files {
+		if f.Mode().IsRegular() && filepath.Ext(f.Name()) == "" {
+			c.lru.add(f.Name(), nil, f.Size())
+		}
+	}
+
+	return c, nil
+}
+
+// Get ...
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	name := c.fileName(key)
+	c.lru.mu.Lock()
+	defer c.lru.mu.Unlock()
+	if _, ok := c.lru.get(name); !ok {
+		return nil, false
+	}
+
+	path := filepath.Join(c.dir, name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Println("DiskCache: could not read a cache file: ", err)
+		c.lru.remove(name)
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Set ...
+func (c *DiskCache) Set(key string, value []byte) {
+	name := c.fileName(key)
+	c.lru.mu.Lock()
+	defer c.lru.mu.Unlock()
+
+	// a partly written file is never read
+	tmp, err := ioutil.TempFile(c.dir, name+".*.tmp")
+	if err != nil {
+		log.Println("DiskCache: could not create a cache file: ", err)
+		return
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		log.Println("DiskCache: could not write a cache file: ", err)
+		_ = os.Remove(tmp.Name())
+		return
+	}
+
+	c.lru.add(name, nil, int64(len(value)))
+}
+
+// Delete ...
+func (c *DiskCache) Delete(key string) {
+	c.lru.mu.Lock()
+	defer c.lru.mu.Unlock()
+	c.lru.remove(c.fileName(key))
+}
+
+// Stats ...
+func (c *DiskCache) Stats() CacheStats {
+	return c.lru.stats()
+}
+
+func (c *DiskCache) fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *DiskCache) removeFile(name string) {
+	if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+		log.Println("DiskCache: could not remove a cache file: ", err)
+	}
+}
+
+// lru tracks the entries of a backend from the most to the least recently used, its callers hold mu
+type lru struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	bytes     int64
+	evictions int64
+	order     *list.List
+	entries   map[string]*list.Element
+	onEvict   func(key string)
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+	size  int64
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{maxBytes: maxBytes, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (l *lru) get(key string) (*lruEntry, bool) {
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruEntry), true
+}
+
+func (l *lru) add(key string, value []byte, size int64) {
+	if el, ok := l.entries[key]; ok {
+		l.bytes -= el.Value.(*lruEntry).size
+		l.order.Remove(el)
+		delete(l.entries, key)
+	}
+	if l.maxBytes > 0 && size > l.maxBytes {
+		// an entry larger than the whole cache is not kept
+		l.evict(key)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, size: size})
+	l.bytes += size
+	for l.maxBytes > 0 && l.bytes > l.maxBytes {
+		oldest := l.order.Back().Value.(*lruEntry)
+		l.remove(oldest.key)
+		l.evictions++
+	}
+}
+
+func (l *lru) remove(key string) {
+	if el, ok := l.entries[key]; ok {
+		l.bytes -= el.Value.(*lruEntry).size
+		l.order.Remove(el)
+		delete(l.entries, key)
+	}
+	l.evict(key)
+}
+
+func (l *lru) evict(key string) {
+	if l.onEvict != nil {
+		l.onEvict(key)
+	}
+}
+
+func (l *lru) stats() CacheStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return CacheStats{Entries: len(l.entries), Bytes: l.bytes, Evictions: l.evictions}
+}
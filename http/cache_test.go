@@ -0,0 +1,219 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// etagServer serves a versioned document with cacheControl, answering 304 to requests with its current etag
+func etagServer(cacheControl string, version *int32) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Method != http.MethodGet {
+			atomic.AddInt32(version, 1)
+			return
+		}
+		etag := fmt.Sprintf(`"v%d"`, atomic.LoadInt32(version))
+		w.Header().Set("ETag", etag)
+		if cacheControl != "" {
+			w.Header().Set("Cache-Control", cacheControl)
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("document " + etag))
+	}))
+	return srv, &calls
+}
+
+func TestCacheFreshResponses(t *testing.T) {
+	var version int32
+	srv, calls := etagServer("max-age=60", &version)
+	defer srv.Close()
+
+	now := time.Now()
+	cache := NewCache(NewMemoryCache(0))
+	cache.now = func() time.Time { return now }
+	client := NewClientProvider(time.Second)
+	client.SetCache(cache)
+
+	for i := 0; i < 3; i++ {
+		status, body, err := client.Request(srv.URL, "GET", nil, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, `document "v0"`, string(body))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+	// stale, revalidated with the etag
+	now = now.Add(2 * time.Minute)
+	status, body, headers, err := client.RequestWithHeaders(srv.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `document "v0"`, string(body))
+	assert.Equal(t, "1", headers["X-From-Cache"][0])
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// a successful update invalidates the cached response
+	_, _, err = client.Request(srv.URL, "PUT", nil, []byte("{}"), nil)
+	assert.NoError(t, err)
+	_, body, err = client.Request(srv.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `document "v1"`, string(body))
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(1), stats.Revalidated)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestCacheControlDirectives(t *testing.T) {
+	var version int32
+	srv, calls := etagServer("no-cache", &version)
+	defer srv.Close()
+
+	cache := NewCache(NewMemoryCache(0))
+	client := NewClientProvider(time.Second)
+	client.SetCache(cache)
+	for i := 0; i < 2; i++ {
+		_, body, err := client.Request(srv.URL, "GET", nil, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `document "v0"`, string(body))
+	}
+	// no-cache responses are always revalidated
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	assert.Equal(t, int64(1), cache.Stats().Revalidated)
+
+	noStore, noStoreCalls := etagServer("no-store", &version)
+	defer noStore.Close()
+	for i := 0; i < 2; i++ {
+		_, _, err := client.Request(noStore.URL, "GET", nil, nil, nil)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(noStoreCalls))
+	assert.Equal(t, 1, cache.Stats().Entries)
+
+	// the caller can bypass the cache
+	_, _, err := client.Request(srv.URL, "GET", map[string]string{"Cache-Control": "no-store"}, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestCacheVary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	client.SetCache(NewCache(NewMemoryCache(0)))
+	for _, lang := range []string{"en", "fr", "fr"} {
+		_, body, err := client.Request(srv.URL, "GET", map[string]string{"Accept-Language": lang}, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, lang, string(body))
+	}
+}
+
+func TestCacheAuthorization(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("data of " + r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	client.SetCache(NewCache(NewMemoryCache(0)))
+	for _, token := range []string{"Bearer a", "Bearer b", "Bearer a"} {
+		_, body, err := client.Request(srv.URL, "GET", map[string]string{"Authorization": token}, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "data of "+token, string(body))
+	}
+}
+
+func TestCacheLargeResponses(t *testing.T) {
+	var calls int32
+	large := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		// flushed in two parts so that the length is unknown
+		_, _ = w.Write([]byte(large[:50]))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(large[50:]))
+	}))
+	defer srv.Close()
+
+	cache := NewCache(NewMemoryCache(0))
+	cache.SetMaxEntrySize(60)
+	client := NewClientProvider(time.Second)
+	client.SetCache(cache)
+	for i := 0; i < 2; i++ {
+		_, body, err := client.Request(srv.URL, "GET", nil, nil, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, large, string(body))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, 0, cache.Stats().Entries)
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	c := NewMemoryCache(10)
+	c.Set("a", []byte("1234"))
+	c.Set("b", []byte("1234"))
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", []byte("1234"))
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	c.Set("d", []byte("too large for the cache"))
+	_, ok = c.Get("d")
+	assert.False(t, ok)
+	assert.Equal(t, CacheStats{Entries: 2, Bytes: 10, Evictions: 1}, c.Stats())
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := NewDiskCache(dir, 8)
+	assert.NoError(t, err)
+	c.Set("a", []byte("1234"))
+	c.Set("b", []byte("5678"))
+	data, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1234", string(data))
+
+	// the entries survive a restart
+	c, err = NewDiskCache(dir, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, CacheStats{Entries: 2, Bytes: 8}, c.Stats())
+	c.Set("c", []byte("9"))
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Stats().Entries)
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	c.Delete("c")
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+}
@@ -2,15 +2,30 @@ package http
 
 import (
 	"bytes"
-	"encoding/csv"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"time"
 )
 
+// ErrNotClientProvider is returned by the clients configuring their http client when it is not a *ClientProvider
+var ErrNotClientProvider = errors.New("the http client is not a *http.ClientProvider")
+
 // ClientProvider ...
 type ClientProvider struct {
 	httpclient *http.Client
+
+	transport   http.RoundTripper
+	recorder    *Recorder
+	retryPolicy *RetryPolicy
+	limiter     *RateLimiter
+	cache       *Cache
+	middlewares []Middleware
 }
 
 // NewClientProvider initiate a new client object
@@ -22,112 +37,179 @@ func NewClientProvider(timeout time.Duration) *ClientProvider {
 	}
 }
 
+// buildTransport stacks the configured layers, whatever the order they were set in:
+// the middlewares see the requests first, then the cache answers, then the retries, each attempt waiting
+// for the rate limiter before reaching the network or the recorder
+func (h *ClientProvider) buildTransport() {
+	rt := h.transport
+	if h.recorder != nil {
+		rt = &recorderTransport{recorder: h.recorder, next: h.transport}
+	}
+	if h.limiter != nil {
+		rt = newLimitTransport(rt, h.limiter)
+	}
+	if h.retryPolicy != nil {
+		rt = newRetryTransport(rt, *h.retryPolicy)
+	}
+	if h.cache != nil {
+		rt = newCacheTransport(rt, h.cache)
+	}
+	if len(h.middlewares) > 0 && rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		rt = h.middlewares[i](rt)
+	}
+	h.httpclient.Transport = rt
+}
+
+// SetTransport replaces the transport reaching the network, http.DefaultTransport when nil,
+// e.g. to use a proxy or custom TLS settings. The retries, rate limits, cache and recorder are stacked over it.
+func (h *ClientProvider) SetTransport(transport http.RoundTripper) {
+	h.transport = transport
+	h.buildTransport()
+}
+
 // Response returned from http request
 type Response struct {
 	StatusCode int
 	Body       []byte
 }
 
-// Request http
-func (h *ClientProvider) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (statusCode int, resBody []byte, err error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
-	if err != nil {
-		return 0, nil, err
-	}
+// StreamResponse is a response whose body is read by the caller, who must close it
+type StreamResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+}
 
-	if cType, ok := header["Content-Type"]; !ok || cType == "application/json" {
-		req.Header.Add("Content-Type", "application/json")
-		delete(header, "Content-Type")
-	}
+// Close discards the rest of the body so that the connection can be reused, and closes it
+func (r *StreamResponse) Close() error {
+	_, _ = io.Copy(ioutil.Discard, r.Body)
+	return r.Body.Close()
+}
 
-	if header != nil {
-		for k, v := range header {
-			req.Header.Add(k, v)
-		}
+// Bytes reads the whole body and closes it
+func (r *StreamResponse) Bytes() ([]byte, error) {
+	defer r.close()
+	return ioutil.ReadAll(r.Body)
+}
+
+// DecodeJSON decodes the body into v and closes it
+func (r *StreamResponse) DecodeJSON(v interface{}) error {
+	defer r.close()
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+func (r *StreamResponse) close() {
+	if err := r.Close(); err != nil {
+		log.Printf("Err: %s", err.Error())
 	}
+}
+
+// StatusError is returned by the JSON helpers when the response status is not 2xx
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+// Error ...
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, string(e.Body))
+}
+
+// Do sends a request with a streaming body, the caller must close the response.
+// The body is sent as json unless header has another Content-Type, header and params are not modified.
+func (h *ClientProvider) Do(ctx context.Context, method string, url string, header map[string]string, body io.Reader, params map[string]string) (*StreamResponse, error) {
+	return h.Send(ctx, method, url,
+		WithHeaders(header),
+		WithQueryParams(params),
+		WithBody(body, "application/json"))
+}
 
-	if params != nil {
-		q := req.URL.Query()
-		for k, v := range params {
-			q.Add(k, v)
+// RequestJSON sends in encoded as json, unless nil, and decodes a 2xx response into out, unless nil.
+// Other statuses are returned as a *StatusError.
+func (h *ClientProvider) RequestJSON(ctx context.Context, method string, url string, header map[string]string, in interface{}, out interface{}) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
 		}
-		req.URL.RawQuery = q.Encode()
+		body = bytes.NewReader(data)
 	}
 
-	// Do request
-	res, err := h.httpclient.Do(req)
+	res, err := h.Do(ctx, method, url, header, body, nil)
 	if err != nil {
-		return 0, nil, err
+		return 0, err
 	}
 
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(res.Body)
-	if err != nil {
-		return 0, nil, err
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		data, err := res.Bytes()
+		if err != nil {
+			return res.StatusCode, err
+		}
+		return res.StatusCode, &StatusError{StatusCode: res.StatusCode, Body: data}
+	}
+	if out == nil {
+		return res.StatusCode, res.Close()
 	}
 
-	return res.StatusCode, buf.Bytes(), nil
+	return res.StatusCode, res.DecodeJSON(out)
 }
 
-// RequestWithHeaders requests http and returns headers too
-func (h *ClientProvider) RequestWithHeaders(url string, method string, header map[string]string, body []byte, params map[string]string) (statusCode int, resBody []byte, resHeaders map[string][]string, err error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
-	if err != nil {
-		return 0, nil, nil, err
-	}
+// GetJSON decodes the json document at url into out
+func (h *ClientProvider) GetJSON(ctx context.Context, url string, header map[string]string, out interface{}) (int, error) {
+	return h.RequestJSON(ctx, http.MethodGet, url, header, nil, out)
+}
 
-	if cType, ok := header["Content-Type"]; !ok || cType == "application/json" {
-		req.Header.Add("Content-Type", "application/json")
-		delete(header, "Content-Type")
-	}
+// PostJSON posts in as json and decodes the response into out
+func (h *ClientProvider) PostJSON(ctx context.Context, url string, header map[string]string, in interface{}, out interface{}) (int, error) {
+	return h.RequestJSON(ctx, http.MethodPost, url, header, in, out)
+}
 
-	if header != nil {
-		for k, v := range header {
-			req.Header.Add(k, v)
-		}
-	}
+// Request http
+func (h *ClientProvider) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (statusCode int, resBody []byte, err error) {
+	statusCode, resBody, _, err = h.RequestWithHeaders(url, method, header, body, params)
+	return statusCode, resBody, err
+}
 
-	if params != nil {
-		q := req.URL.Query()
-		for k, v := range params {
-			q.Add(k, v)
-		}
-		req.URL.RawQuery = q.Encode()
-	}
+// RequestContext is Request bound to ctx
+func (h *ClientProvider) RequestContext(ctx context.Context, url string, method string, header map[string]string, body []byte, params map[string]string) (statusCode int, resBody []byte, err error) {
+	statusCode, resBody, _, err = h.requestWithHeaders(ctx, url, method, header, body, params)
+	return statusCode, resBody, err
+}
 
-	// Do request
-	res, err := h.httpclient.Do(req)
+// RequestWithHeaders requests http and returns headers too
+func (h *ClientProvider) RequestWithHeaders(url string, method string, header map[string]string, body []byte, params map[string]string) (statusCode int, resBody []byte, resHeaders map[string][]string, err error) {
+	return h.requestWithHeaders(context.Background(), url, method, header, body, params)
+}
+
+func (h *ClientProvider) requestWithHeaders(ctx context.Context, url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, map[string][]string, error) {
+	res, err := h.Do(ctx, method, url, header, bytes.NewBuffer(body), params)
 	if err != nil {
 		return 0, nil, nil, err
 	}
 
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(res.Body)
+	resBody, err := res.Bytes()
 	if err != nil {
 		return 0, nil, nil, err
 	}
 
-	return res.StatusCode, buf.Bytes(), res.Header, nil
+	return res.StatusCode, resBody, res.Header, nil
 }
 
-// RequestCSV requests http API that returns csv result
+// RequestCSV requests http API that returns csv result, the header row included.
+// Use StreamCSV to read large documents, other delimiters or to map the rows by column.
 func (h *ClientProvider) RequestCSV(url string) ([][]string, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("Err: %s", err.Error())
-		}
-	}()
-	reader := csv.NewReader(resp.Body)
-	reader.Comma = ','
-	data, err := reader.ReadAll()
+	var rows [][]string
+	err := h.EachCSVRow(context.Background(), url, CSVOptions{NoHeader: true}, func(row CSVRow) error {
+		rows = append(rows, row.Record)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return data, nil
+	return rows, nil
 }
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoStreamsBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		_, _ = w.Write([]byte(strings.ToUpper(string(body))))
+	}))
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	res, err := client.Do(context.Background(), "POST", srv.URL, nil, strings.NewReader("streamed"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "POST", res.Header.Get("X-Method"))
+	body, err := res.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "STREAMED", string(body))
+}
+
+func TestDoContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := NewClientProvider(time.Minute).Do(ctx, "GET", srv.URL, nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestRequestJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/orgs":
+			in := map[string]string{}
+			_ = json.NewDecoder(r.Body).Decode(&in)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]string{"id": "1", "name": in["name"]})
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]string{"name": "Linux Foundation"})
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	var org struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	status, err := client.GetJSON(context.Background(), srv.URL+"/org", nil, &org)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Linux Foundation", org.Name)
+
+	status, err = client.PostJSON(context.Background(), srv.URL+"/orgs", nil, map[string]string{"name": "CNCF"}, &org)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "1", org.ID)
+	assert.Equal(t, "CNCF", org.Name)
+
+	status, err = client.GetJSON(context.Background(), srv.URL+"/missing", nil, &org)
+	assert.Equal(t, http.StatusNotFound, status)
+	statusErr, ok := err.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, `{"error":"not found"}`, string(statusErr.Body))
+}
+
+func TestRequestCSVUsesClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte("name,domain\nLinux Foundation,linuxfoundation.org\n"))
+	}))
+	defer srv.Close()
+
+	client := NewClientProvider(50 * time.Millisecond)
+	rows, err := client.RequestCSV(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"name", "domain"}, {"Linux Foundation", "linuxfoundation.org"}}, rows)
+
+	_, err = client.RequestCSV(srv.URL + "/slow")
+	assert.Error(t, err)
+}
@@ -0,0 +1,340 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrFieldCount is the error of a row whose number of fields differs from the header
+var ErrFieldCount = errors.New("wrong number of fields")
+
+// CSVOptions configures the parsing of a csv or tsv document
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' by default and '\t' for tsv
+	Comma rune
+	// Comment starts the lines to ignore, none by default
+	Comment rune
+	// LazyQuotes accepts quotes in unquoted fields and unescaped quotes in quoted fields
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	// Header names the columns of a document without header row, the first row is the header otherwise
+	Header []string
+	// NoHeader reads the first row as data, the columns having no name unless Header is set
+	NoHeader bool
+	// OnRowError receives the malformed rows, which are then skipped. The reading stops when it returns an error,
+	// or at the first malformed row when it is nil.
+	OnRowError func(err *RowError) error
+}
+
+// RowError is a malformed row, a *csv.ParseError giving its line, or a row which cannot be decoded.
+// Row is the number of the record, not of the line: it differs once a quoted field spans several lines.
+type RowError struct {
+	Row    int
+	Record []string
+	Err    error
+}
+
+// Error ...
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err.Error())
+}
+
+// Unwrap ...
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// CSVRow is a row of a csv document, Row is its record number in the document, the header row being the first one
+type CSVRow struct {
+	Row    int
+	Record []string
+	Header []string
+}
+
+// Get returns the value of column, or an empty string
+func (r CSVRow) Get(column string) string {
+	for i, name := range r.Header {
+		if name == column && i < len(r.Record) {
+			return r.Record[i]
+		}
+	}
+	return ""
+}
+
+// Map returns the values by column
+func (r CSVRow) Map() map[string]string {
+	values := make(map[string]string, len(r.Header))
+	for i, name := range r.Header {
+		if i < len(r.Record) {
+			values[name] = r.Record[i]
+		}
+	}
+	return values
+}
+
+// Decode sets the fields of the struct pointed by v from the columns named by their csv tag, or by their name
+// ignoring case. The fields can be strings, numbers, booleans or encoding.TextUnmarshaler, a column named "-" is ignored.
+func (r CSVRow) Decode(v interface{}) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.Elem().Kind() != reflect.Struct {
+		return errors.New("Decode: v must be a pointer to a struct")
+	}
+	s := ptr.Elem()
+	columns := r.Map()
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Tag.Get("csv")
+		if name == "-" {
+			continue
+		}
+
+		var value string
+		var ok bool
+		if name != "" {
+			value, ok = columns[name]
+		} else {
+			for column, v := range columns {
+				if strings.EqualFold(column, field.Name) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(s.Field(i), value); err != nil {
+			return &RowError{Row: r.Row, Record: r.Record, Err: fmt.Errorf("field %s: %w", field.Name, err)}
+		}
+	}
+
+	return nil
+}
+
+func setField(f reflect.Value, value string) error {
+	if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	value = strings.TrimSpace(value)
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+		return nil
+	}
+	if value == "" {
+		// an empty cell leaves the zero value
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+
+	return nil
+}
+
+// CSVReader iterates over the rows of a csv document, it must be closed
+type CSVReader struct {
+	body    io.Closer
+	reader  *csv.Reader
+	options CSVOptions
+	header  []string
+	fields  int
+	rows    int
+	row     CSVRow
+	err     error
+}
+
+// NewCSVReader reads the csv document of r, which may be gzip compressed
+func NewCSVReader(r io.Reader, options CSVOptions) (*CSVReader, error) {
+	buffered := bufio.NewReader(r)
+	var input io.Reader = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		input = gz
+	}
+
+	reader := csv.NewReader(input)
+	reader.Comma = ','
+	if options.Comma != 0 {
+		reader.Comma = options.Comma
+	}
+	reader.Comment = options.Comment
+	reader.LazyQuotes = options.LazyQuotes
+	reader.TrimLeadingSpace = options.TrimLeadingSpace
+	// the field count is checked against the header, so that a malformed row does not stop the reading
+	reader.FieldsPerRecord = -1
+
+	c := &CSVReader{reader: reader, options: options, header: options.Header, fields: len(options.Header)}
+	if options.NoHeader || len(options.Header) > 0 {
+		return c, nil
+	}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.rows++
+	c.header = header
+	c.fields = len(header)
+
+	return c, nil
+}
+
+// StreamCSV requests the csv document at url and returns a reader of its rows.
+// A gzip compressed document is decompressed, a status other than 2xx is returned as a *StatusError.
+func (h *ClientProvider) StreamCSV(ctx context.Context, url string, options CSVOptions, opts ...RequestOption) (*CSVReader, error) {
+	res, err := h.Send(ctx, http.MethodGet, url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		data, err := res.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return nil, &StatusError{StatusCode: res.StatusCode, Body: data}
+	}
+
+	reader, err := NewCSVReader(res.Body, options)
+	if err != nil {
+		res.close()
+		return nil, err
+	}
+	reader.body = res
+
+	return reader, nil
+}
+
+// EachCSVRow calls fn with every row of the csv document at url, until fn returns an error
+func (h *ClientProvider) EachCSVRow(ctx context.Context, url string, options CSVOptions, fn func(row CSVRow) error, opts ...RequestOption) error {
+	reader, err := h.StreamCSV(ctx, url, options, opts...)
+	if err != nil {
+		return err
+	}
+	defer reader.close()
+
+	for reader.Next() {
+		if err := fn(reader.Row()); err != nil {
+			return err
+		}
+	}
+
+	return reader.Err()
+}
+
+// Header returns the column names
+func (c *CSVReader) Header() []string {
+	return c.header
+}
+
+// Next reads the next row, it returns false at the end of the document or on error
+func (c *CSVReader) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	for {
+		record, err := c.reader.Read()
+		if err == io.EOF {
+			return false
+		}
+		c.rows++
+
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			c.err = err
+			return false
+		}
+		if err == nil && c.fields == 0 {
+			c.fields = len(record)
+		}
+		if err == nil && len(record) != c.fields {
+			err = fmt.Errorf("%w: %d instead of %d", ErrFieldCount, len(record), c.fields)
+		}
+		if err != nil {
+			rowErr := &RowError{Row: c.rows, Record: record, Err: err}
+			if c.options.OnRowError == nil {
+				c.err = rowErr
+				return false
+			}
+			if c.err = c.options.OnRowError(rowErr); c.err != nil {
+				return false
+			}
+			continue
+		}
+
+		c.row = CSVRow{Row: c.rows, Record: record, Header: c.header}
+		return true
+	}
+}
+
+// Row returns the row read by Next
+func (c *CSVReader) Row() CSVRow {
+	return c.row
+}
+
+// Err returns the error that stopped Next, if any
+func (c *CSVReader) Err() error {
+	return c.err
+}
+
+// Close closes the response of the document, if any
+func (c *CSVReader) Close() error {
+	if c.body == nil {
+		return nil
+	}
+	return c.body.Close()
+}
+
+func (c *CSVReader) close() {
+	if err := c.Close(); err != nil {
+		log.Printf("Err: %s", err.Error())
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type orgRow struct {
+	Name    string `csv:"name"`
+	Domain  string `csv:"domain"`
+	Members int    `csv:"members"`
+	Active  bool
+	Joined  time.Time `csv:"joined"`
+	Notes   string    `csv:"-"`
+}
+
+func csvServer(documents map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := documents[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".gz") {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			_, _ = gz.Write([]byte(doc))
+			_ = gz.Close()
+			w.Header().Set("Content-Type", "application/gzip")
+			_, _ = w.Write(buf.Bytes())
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	}))
+}
+
+func TestStreamCSVDecode(t *testing.T) {
+	doc := "name,domain,members,active,joined\n" +
+		"\"Linux Foundation, US\",linuxfoundation.org,10,true,2020-01-02T00:00:00Z\n" +
+		"CNCF,cncf.io,,false,2021-03-04T00:00:00Z\n"
+	srv := csvServer(map[string]string{"/orgs.csv": doc, "/orgs.csv.gz": doc})
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	for _, path := range []string{"/orgs.csv", "/orgs.csv.gz"} {
+		reader, err := client.StreamCSV(context.Background(), srv.URL+path, CSVOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"name", "domain", "members", "active", "joined"}, reader.Header())
+
+		var orgs []orgRow
+		for reader.Next() {
+			var org orgRow
+			assert.NoError(t, reader.Row().Decode(&org))
+			orgs = append(orgs, org)
+		}
+		assert.NoError(t, reader.Err())
+		assert.NoError(t, reader.Close())
+
+		assert.Len(t, orgs, 2)
+		assert.Equal(t, orgRow{Name: "Linux Foundation, US", Domain: "linuxfoundation.org", Members: 10, Active: true,
+			Joined: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}, orgs[0])
+		assert.Equal(t, 0, orgs[1].Members)
+	}
+
+	_, err := client.StreamCSV(context.Background(), srv.URL+"/missing.csv", CSVOptions{})
+	statusErr, ok := err.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestEachCSVRowTSV(t *testing.T) {
+	srv := csvServer(map[string]string{"/orgs.tsv": "# exported\nLinux Foundation\tlinuxfoundation.org\nCNCF\tcncf.io\n"})
+	defer srv.Close()
+
+	var rows []map[string]string
+	options := CSVOptions{Comma: '\t', Comment: '#', Header: []string{"name", "domain"}}
+	err := NewClientProvider(time.Second).EachCSVRow(context.Background(), srv.URL+"/orgs.tsv", options, func(row CSVRow) error {
+		rows = append(rows, row.Map())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "Linux Foundation", "domain": "linuxfoundation.org"},
+		{"name": "CNCF", "domain": "cncf.io"},
+	}, rows)
+}
+
+func TestCSVRowErrors(t *testing.T) {
+	doc := "name,domain\na,a.org\nb\nc,\"c.org\n\"d\"\"\",d.org\ne,e.org\n"
+
+	// the reading stops at the first malformed row by default
+	reader, err := NewCSVReader(strings.NewReader("name,domain\na,a.org\nb\nc,c.org\n"), CSVOptions{})
+	assert.NoError(t, err)
+	assert.True(t, reader.Next())
+	assert.False(t, reader.Next())
+	var rowErr *RowError
+	assert.True(t, errors.As(reader.Err(), &rowErr))
+	assert.Equal(t, 3, rowErr.Row)
+	assert.True(t, errors.Is(reader.Err(), ErrFieldCount))
+
+	var rowErrors []*RowError
+	reader, err = NewCSVReader(strings.NewReader(doc), CSVOptions{OnRowError: func(err *RowError) error {
+		rowErrors = append(rowErrors, err)
+		return nil
+	}})
+	assert.NoError(t, err)
+	var names []string
+	for reader.Next() {
+		names = append(names, reader.Row().Get("name"))
+	}
+	assert.NoError(t, reader.Err())
+	assert.Equal(t, []string{"a", "e"}, names)
+	assert.Len(t, rowErrors, 2)
+	assert.True(t, errors.Is(rowErrors[0], ErrFieldCount))
+	var parseErr *csv.ParseError
+	assert.True(t, errors.As(rowErrors[1], &parseErr))
+
+	var org orgRow
+	err = CSVRow{Row: 2, Header: []string{"members"}, Record: []string{"many"}}.Decode(&org)
+	assert.True(t, errors.As(err, &rowErr))
+	assert.Equal(t, 2, rowErr.Row)
+}
+
+func TestCSVRowDecodeBlankHeader(t *testing.T) {
+	reader, err := NewCSVReader(strings.NewReader("name,,other\nlinux,x,y\nfoo,\"multi\nline\",z\n"), CSVOptions{})
+	assert.NoError(t, err)
+
+	// the blank column is not matched by the untagged fields
+	var rows []struct {
+		Name  string
+		Count int
+	}
+	var numbers []int
+	for reader.Next() {
+		var row struct {
+			Name  string
+			Count int
+		}
+		assert.NoError(t, reader.Row().Decode(&row))
+		rows = append(rows, row)
+		numbers = append(numbers, reader.Row().Row)
+	}
+	assert.NoError(t, reader.Err())
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "linux", rows[0].Name)
+	assert.Equal(t, 0, rows[0].Count)
+	// the rows are numbered by record, the quoted field spanning two lines counts once
+	assert.Equal(t, []int{2, 3}, numbers)
+}
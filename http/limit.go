@@ -0,0 +1,235 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit limits the requests sent to a host. Rate is the number of requests per second allowed on average,
+// with bursts of up to Burst requests, zero meaning no limit. MaxConcurrent caps the requests in flight,
+// a request being in flight until its response body is closed, zero meaning no cap.
+type HostLimit struct {
+	Rate          float64
+	Burst         int
+	MaxConcurrent int
+}
+
+// RateLimiter limits the requests per host. It is safe for concurrent use, and one limiter can be shared by
+// several ClientProviders, so that the orgs, users and affiliation clients calling the same hosts share their limits.
+// Besides the configured limits, it slows down when the responses report an exhausted quota
+// with Retry-After or X-RateLimit-Remaining and X-RateLimit-Reset, and once less than a tenth of X-RateLimit-Limit
+// remains it paces the requests to spread the remaining quota until the reset, when the responses report that limit.
+type RateLimiter struct {
+	mu       sync.Mutex
+	defaults HostLimit
+	limits   map[string]HostLimit
+	hosts    map[string]*hostState
+	now      func() time.Time
+}
+
+// hostState is the token bucket and concurrency slots of a host
+type hostState struct {
+	limit   HostLimit
+	tokens  float64
+	last    time.Time
+	blocked time.Time
+	slots   chan struct{}
+}
+
+// NewRateLimiter limits every host with defaults, unless SetHostLimit overrides it
+func NewRateLimiter(defaults HostLimit) *RateLimiter {
+	return &RateLimiter{
+		defaults: defaults,
+		limits:   make(map[string]HostLimit),
+		hosts:    make(map[string]*hostState),
+		now:      time.Now,
+	}
+}
+
+// SetHostLimit limits host, with its port if not the default one, with limit
+func (l *RateLimiter) SetHostLimit(host string, limit HostLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	host = strings.ToLower(host)
+	l.limits[host] = limit
+	// the state is rebuilt with the new limit, requests in flight keep their previous slot
+	delete(l.hosts, host)
+}
+
+// Wait blocks until a request to host is allowed or ctx is done.
+// The returned release must be called once the request is over to free its concurrency slot.
+func (l *RateLimiter) Wait(ctx context.Context, host string) (release func(), err error) {
+	state := l.state(host)
+	release = func() {}
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var once sync.Once
+		release = func() { once.Do(func() { <-state.slots }) }
+	}
+
+	for {
+		wait := l.reserve(state)
+		if wait <= 0 {
+			return release, nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Observe adapts the limits of host to the quota reported by res
+func (l *RateLimiter) Observe(host string, res *http.Response) {
+	state := l.state(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	if d, ok := retryAfter(res, now); ok {
+		state.block(now.Add(d))
+		return
+	}
+
+	remaining, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Remaining"), 10, 64)
+	if err != nil || remaining <= 0 {
+		return
+	}
+	// without the limit the remaining quota cannot be judged, only Retry-After is honoured
+	limit, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Limit"), 10, 64)
+	if err != nil || remaining*10 > limit {
+		// plenty of quota left
+		return
+	}
+	reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	if window := time.Unix(reset, 0).Sub(now); window > 0 {
+		// spread the remaining quota until the reset
+		state.block(now.Add(window / time.Duration(remaining)))
+	}
+}
+
+func (l *RateLimiter) state(host string) *hostState {
+	host = strings.ToLower(host)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if state, ok := l.hosts[host]; ok {
+		return state
+	}
+
+	limit, ok := l.limits[host]
+	if !ok {
+		limit = l.defaults
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	state := &hostState{limit: limit, tokens: float64(limit.Burst), last: l.now()}
+	if limit.MaxConcurrent > 0 {
+		state.slots = make(chan struct{}, limit.MaxConcurrent)
+	}
+	l.hosts[host] = state
+
+	return state
+}
+
+// reserve takes a token of state and returns zero, or returns how long to wait before trying again
+func (l *RateLimiter) reserve(state *hostState) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Before(state.blocked) {
+		return state.blocked.Sub(now)
+	}
+	if state.limit.Rate <= 0 {
+		return 0
+	}
+
+	state.tokens += now.Sub(state.last).Seconds() * state.limit.Rate
+	if max := float64(state.limit.Burst); state.tokens > max {
+		state.tokens = max
+	}
+	state.last = now
+	if state.tokens >= 1 {
+		state.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - state.tokens) / state.limit.Rate * float64(time.Second))
+}
+
+// block delays the requests until at least t
+func (s *hostState) block(t time.Time) {
+	if t.After(s.blocked) {
+		s.blocked = t
+	}
+}
+
+// SetRateLimiter limits the requests of the provider with limiter, which may be shared with other providers.
+// The limits apply to every attempt of a retried request.
+func (h *ClientProvider) SetRateLimiter(limiter *RateLimiter) {
+	h.limiter = limiter
+	h.buildTransport()
+}
+
+// limitTransport is a RoundTripper waiting for a RateLimiter before each request
+type limitTransport struct {
+	next    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func newLimitTransport(next http.RoundTripper, limiter *RateLimiter) *limitTransport {
+	return &limitTransport{next: next, limiter: limiter}
+}
+
+// RoundTrip ...
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	host := req.URL.Host
+	release, err := t.limiter.Wait(req.Context(), host)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	t.limiter.Observe(host, res)
+	res.Body = &releaseBody{ReadCloser: res.Body, release: release}
+
+	return res, nil
+}
+
+// releaseBody frees the concurrency slot of its request when closed
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close ...
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := NewRateLimiter(HostLimit{Rate: 2, Burst: 2})
+	limiter.now = func() time.Time { return now }
+	limiter.SetHostLimit("Slow.Example.com", HostLimit{Rate: 0.5})
+
+	state := limiter.state("api.example.com")
+	assert.Equal(t, time.Duration(0), limiter.reserve(state))
+	assert.Equal(t, time.Duration(0), limiter.reserve(state))
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(state))
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), limiter.reserve(state))
+
+	// host limits are case insensitive and have a burst of at least 1
+	slow := limiter.state("slow.example.com")
+	assert.Equal(t, time.Duration(0), limiter.reserve(slow))
+	assert.Equal(t, 2*time.Second, limiter.reserve(slow))
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := NewRateLimiter(HostLimit{})
+	limiter.now = func() time.Time { return now }
+	state := limiter.state("api.example.com")
+	observe := func(header map[string]string) time.Duration {
+		res := &http.Response{Header: http.Header{}}
+		for k, v := range header {
+			res.Header.Set(k, v)
+		}
+		limiter.Observe("api.example.com", res)
+		return limiter.reserve(state)
+	}
+
+	assert.Equal(t, time.Duration(0), observe(map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "50", "X-RateLimit-Reset": "1100"}))
+	// less than a tenth of the quota left is spread until the reset
+	assert.Equal(t, 10*time.Second, observe(map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "10", "X-RateLimit-Reset": "1100"}))
+	now = now.Add(10 * time.Second)
+	// no pacing without the limit to compare the remaining quota to
+	assert.Equal(t, time.Duration(0), observe(map[string]string{"X-RateLimit-Remaining": "5", "X-RateLimit-Reset": "1100"}))
+	assert.Equal(t, 30*time.Second, observe(map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1040"}))
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, 5*time.Second, observe(map[string]string{"Retry-After": "5"}))
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	// the limiter is shared by two providers
+	limiter := NewRateLimiter(HostLimit{MaxConcurrent: 2})
+	clients := []*ClientProvider{NewClientProvider(time.Second), NewClientProvider(time.Second)}
+	for _, c := range clients {
+		c.SetRateLimiter(limiter)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(c *ClientProvider) {
+			defer wg.Done()
+			status, _, err := c.Request(srv.URL, "GET", nil, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, status)
+		}(clients[i%2])
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+}
+
+func TestRateLimiterWithRetries(t *testing.T) {
+	srv, calls := failingServer(1, http.StatusTooManyRequests, map[string]string{"Retry-After": "0"})
+	defer srv.Close()
+
+	limiter := NewRateLimiter(HostLimit{Rate: 20})
+	client := NewClientProvider(time.Second)
+	// the limiter stays under the retries whatever the order of the setters
+	client.SetRateLimiter(limiter)
+	client.SetRetryPolicy(testRetryPolicy())
+	client.SetRateLimiter(limiter)
+
+	start := time.Now()
+	status, _, err := client.Request(srv.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	// the retry waited for a token of the bucket
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestRateLimiterWaitContext(t *testing.T) {
+	limiter := NewRateLimiter(HostLimit{Rate: 0.1, MaxConcurrent: 1})
+	release, err := limiter.Wait(context.Background(), "api.example.com")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, "api.example.com")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// the slot is free but the bucket is empty
+	release()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, "api.example.com")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,259 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request id set by the RequestID middleware
+const RequestIDHeader = "X-Request-ID"
+
+// Middleware wraps the RoundTripper sending the requests
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is a function used as a RoundTripper, to write middlewares
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip ...
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TokenSource provides the bearer tokens, an auth0.ClientProvider for example
+type TokenSource interface {
+	GetToken() (string, error)
+}
+
+// Use adds middlewares to the provider, the first one seeing the requests first.
+// They wrap the cache, retries and rate limiter, so that a request is seen once whatever its attempts.
+func (h *ClientProvider) Use(middlewares ...Middleware) {
+	h.middlewares = append(h.middlewares, middlewares...)
+	h.buildTransport()
+}
+
+// BearerHeader returns the authorization header of a token of source, to pass to Request
+func BearerHeader(source TokenSource) (map[string]string, error) {
+	headers := make(map[string]string)
+	token, err := source.GetToken()
+	if err != nil {
+		return headers, err
+	}
+	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
+
+	return headers, nil
+}
+
+// BearerAuth authorizes the requests with a token of source, unless they have an Authorization header
+func BearerAuth(source TokenSource) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "" {
+				return next.RoundTrip(req)
+			}
+			token, err := source.GetToken()
+			if err != nil {
+				return nil, err
+			}
+			// a RoundTripper must not modify the caller's request
+			r := req.Clone(req.Context())
+			r.Header.Set("Authorization", fmt.Sprintf("%s %s", "Bearer", token))
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context whose requests are sent with id
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id of ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID sends the request id of the request context in the RequestIDHeader, a new one if it has none,
+// and makes it available to the next middlewares with RequestIDFromContext
+func RequestID() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			id := RequestIDFromContext(req.Context())
+			if id == "" {
+				id = req.Header.Get(RequestIDHeader)
+			}
+			if id == "" {
+				id = uuid.New().String()
+			}
+
+			r := req.Clone(ContextWithRequestID(req.Context(), id))
+			r.Header.Set(RequestIDHeader, id)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+// LogEntry describes a request sent, its url and headers are redacted
+type LogEntry struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestID      string      `json:"request_id,omitempty"`
+	Status         int         `json:"status,omitempty"`
+	DurationMs     int64       `json:"duration_ms"`
+	FromCache      bool        `json:"from_cache,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+}
+
+// LogConfig configures the Logging middleware, the redactions add to the default ones
+type LogConfig struct {
+	// Logger receives the entries, they are written as json with the standard logger by default
+	Logger func(entry LogEntry)
+	// Headers adds the request and response headers to the entries
+	Headers       bool
+	RedactHeaders []string
+	RedactFields  []string
+	Secrets       []string
+}
+
+// Logging logs every request once its response headers are received
+func Logging(config LogConfig) Middleware {
+	redact := newRedactor(config.RedactHeaders, config.RedactFields, config.Secrets)
+	logger := config.Logger
+	if logger == nil {
+		logger = logJSON
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+
+			entry := LogEntry{
+				Method:     req.Method,
+				URL:        redact.url(req.URL),
+				RequestID:  RequestIDFromContext(req.Context()),
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if config.Headers {
+				entry.RequestHeader = redact.header(req.Header)
+			}
+			if err != nil {
+				entry.Error = redact.secrets(err.Error())
+			} else {
+				entry.Status = res.StatusCode
+				entry.FromCache = res.Header.Get("X-From-Cache") != ""
+				if config.Headers {
+					entry.ResponseHeader = redact.header(res.Header)
+				}
+			}
+			logger(entry)
+
+			return res, err
+		})
+	}
+}
+
+func logJSON(entry LogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("Logging: could not encode the log entry: ", err)
+		return
+	}
+	log.Println(string(data))
+}
+
+// HostMetrics are the metrics of the requests sent to a host
+type HostMetrics struct {
+	Requests     int64
+	Errors       int64
+	Statuses     map[int]int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// MeanLatency ...
+func (m HostMetrics) MeanLatency() time.Duration {
+	if m.Requests == 0 {
+		return 0
+	}
+	return m.TotalLatency / time.Duration(m.Requests)
+}
+
+// Metrics counts the requests per host, status and latency, its Middleware can be used by several providers
+type Metrics struct {
+	mu    sync.Mutex
+	hosts map[string]*HostMetrics
+}
+
+// NewMetrics ...
+func NewMetrics() *Metrics {
+	return &Metrics{hosts: make(map[string]*HostMetrics)}
+}
+
+// Middleware records the metrics of the requests, the latency being the time to receive the response headers
+func (m *Metrics) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+			m.record(req.URL.Host, res, err, time.Since(start))
+			return res, err
+		})
+	}
+}
+
+// Snapshot returns a copy of the metrics per host
+func (m *Metrics) Snapshot() map[string]HostMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]HostMetrics, len(m.hosts))
+	for host, metrics := range m.hosts {
+		copied := *metrics
+		copied.Statuses = make(map[int]int64, len(metrics.Statuses))
+		for status, count := range metrics.Statuses {
+			copied.Statuses[status] = count
+		}
+		snapshot[host] = copied
+	}
+
+	return snapshot
+}
+
+// Reset clears the metrics
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hosts = make(map[string]*HostMetrics)
+}
+
+func (m *Metrics) record(host string, res *http.Response, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics, ok := m.hosts[host]
+	if !ok {
+		metrics = &HostMetrics{Statuses: make(map[int]int64)}
+		m.hosts[host] = metrics
+	}
+
+	metrics.Requests++
+	metrics.TotalLatency += latency
+	if latency > metrics.MaxLatency {
+		metrics.MaxLatency = latency
+	}
+	if err != nil {
+		metrics.Errors++
+		return
+	}
+	metrics.Statuses[res.StatusCode]++
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type staticToken struct {
+	token string
+	err   error
+	calls int32
+}
+
+func (s *staticToken) GetToken() (string, error) {
+	atomic.AddInt32(&s.calls, 1)
+	return s.token, s.err
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := NewClientProvider(time.Second)
+	client.Use(trace("first"), trace("second"))
+	client.SetRetryPolicy(testRetryPolicy())
+	client.Use(trace("third"))
+	_, _, err := client.Request(srv.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, order)
+}
+
+func TestBearerAuthAndRequestID(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+
+	source := &staticToken{token: "t0k3n"}
+	client := NewClientProvider(time.Second)
+	client.Use(RequestID(), BearerAuth(source))
+
+	got := send(t, client, srv.URL)
+	assert.Equal(t, "Bearer t0k3n", http.Header(got.Header).Get("Authorization"))
+	assert.NotEmpty(t, http.Header(got.Header).Get(RequestIDHeader))
+
+	// the caller's authorization and request id are kept
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	res, err := client.Send(ctx, "GET", srv.URL, WithBearerToken("other"))
+	assert.NoError(t, err)
+	var echoed echoRequest
+	assert.NoError(t, res.DecodeJSON(&echoed))
+	assert.Equal(t, "Bearer other", http.Header(echoed.Header).Get("Authorization"))
+	assert.Equal(t, "req-1", http.Header(echoed.Header).Get(RequestIDHeader))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&source.calls))
+
+	source.err = errors.New("no token")
+	_, _, err = client.Request(srv.URL, "GET", nil, nil, nil)
+	assert.Error(t, err)
+
+	headers, err := BearerHeader(&staticToken{token: "abc"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer abc"}, headers)
+}
+
+func TestLoggingRedacts(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+
+	var entries []LogEntry
+	client := NewClientProvider(time.Second)
+	client.Use(RequestID(), Logging(LogConfig{
+		Logger:  func(entry LogEntry) { entries = append(entries, entry) },
+		Headers: true,
+		Secrets: []string{"s3cr3t"},
+	}))
+	_, _, err := client.Request(srv.URL+"/path", "GET", map[string]string{"Authorization": "Bearer t0k3n", "X-Note": "s3cr3t"},
+		nil, map[string]string{"access_token": "t0k3n", "name": "linux"})
+	assert.NoError(t, err)
+
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.NotEmpty(t, entry.RequestID)
+	u, err := url.Parse(entry.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, Redacted, u.Query().Get("access_token"))
+	assert.Equal(t, "linux", u.Query().Get("name"))
+	assert.Equal(t, Redacted, entry.RequestHeader.Get("Authorization"))
+	assert.Equal(t, Redacted, entry.RequestHeader.Get("X-Note"))
+	assert.NotEmpty(t, entry.ResponseHeader.Get("Content-Type"))
+}
+
+func TestMetrics(t *testing.T) {
+	srv, _ := failingServer(1, http.StatusServiceUnavailable, nil)
+	defer srv.Close()
+
+	metrics := NewMetrics()
+	client := NewClientProvider(time.Second)
+	client.Use(metrics.Middleware())
+	for i := 0; i < 3; i++ {
+		_, _, _ = client.Request(srv.URL, "GET", nil, nil, nil)
+	}
+	_, _, err := client.Request("http://127.0.0.1:1", "GET", nil, nil, nil)
+	assert.Error(t, err)
+
+	snapshot := metrics.Snapshot()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	assert.Equal(t, int64(3), snapshot[host].Requests)
+	assert.Equal(t, map[int]int64{http.StatusServiceUnavailable: 1, http.StatusOK: 2}, snapshot[host].Statuses)
+	assert.True(t, snapshot[host].MaxLatency >= snapshot[host].MeanLatency())
+	assert.Equal(t, int64(1), snapshot["127.0.0.1:1"].Errors)
+
+	metrics.Reset()
+	assert.Empty(t, metrics.Snapshot())
+}
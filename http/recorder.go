@@ -0,0 +1,314 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNoInteraction is returned in replay mode when no recorded interaction matches the request
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// RecorderMode tells whether a Recorder records or replays
+type RecorderMode int
+
+const (
+	// ModeReplay serves the recorded interactions without network access
+	ModeReplay RecorderMode = iota
+	// ModeRecord sends the requests and records them, Save writes them to the golden file
+	ModeRecord
+)
+
+// Matching tells how a request is matched to a recorded interaction
+type Matching int
+
+const (
+	// MatchStrict matches the method, url, query and body, each interaction being replayed once
+	MatchStrict Matching = iota
+	// MatchLenient matches the method and path, preferring the interactions with the same query and body,
+	// and replays an interaction as many times as needed
+	MatchLenient
+)
+
+// RecorderConfig configures a Recorder, the redactions add to the default ones
+type RecorderConfig struct {
+	Mode          RecorderMode
+	Matching      Matching
+	RedactHeaders []string
+	RedactFields  []string
+	// Secrets are values redacted wherever they appear
+	Secrets []string
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest ...
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse ...
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// golden is the content of a golden file
+type golden struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder is a RoundTripper recording the interactions with the servers to a golden file, or replaying them
+type Recorder struct {
+	path   string
+	config RecorderConfig
+	redact *redactor
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	replayed     []bool
+}
+
+// NewRecorder creates a recorder of the golden file at path, which is read in replay mode
+func NewRecorder(path string, config RecorderConfig) (*Recorder, error) {
+	r := &Recorder{path: path, config: config, redact: newRedactor(config.RedactHeaders, config.RedactFields, config.Secrets)}
+	if config.Mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var g golden
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("could not read golden file %s: %w", path, err)
+	}
+	r.interactions = g.Interactions
+	r.replayed = make([]bool, len(g.Interactions))
+
+	return r, nil
+}
+
+// Interactions returns the recorded or loaded interactions
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to the golden file, it does nothing in replay mode
+func (r *Recorder) Save() error {
+	if r.config.Mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(golden{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// SetRecorder records or replays the requests of the provider with recorder, nil removes it.
+// The recorder stands for the network, the retries, rate limits and cache still apply, and in record mode
+// it sends the requests with the transport of the provider.
+func (h *ClientProvider) SetRecorder(recorder *Recorder) {
+	h.recorder = recorder
+	h.buildTransport()
+}
+
+// RoundTrip records the requests sent with http.DefaultTransport, or replays them
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.roundTrip(req, nil)
+}
+
+// recorderTransport records the requests sent with the transport of a ClientProvider
+type recorderTransport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+// RoundTrip ...
+func (t *recorderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.recorder.roundTrip(req, t.next)
+}
+
+func (r *Recorder) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+	}
+	recorded := r.redactRequest(req, body)
+
+	if r.config.Mode != ModeRecord {
+		interaction, err := r.match(recorded)
+		if err != nil {
+			return nil, err
+		}
+		return interaction.Response.response(req), nil
+	}
+
+	// a RoundTripper must not modify the caller's request
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	res, err := next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, &Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     r.redact.header(res.Header),
+			Body:       r.redact.body(res.Header.Get("Content-Type"), resBody),
+		},
+	})
+
+	return res, nil
+}
+
+// match finds the interaction of req, the first one not replayed yet among the best matches
+func (r *Recorder) match(req RecordedRequest) (*Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	best, bestScore := -1, 0
+	for i, interaction := range r.interactions {
+		if r.config.Matching == MatchStrict && r.replayed[i] {
+			continue
+		}
+		score := matchScore(r.config.Matching, interaction.Request, req)
+		if score > bestScore || (score == bestScore && score > 0 && r.replayed[best] && !r.replayed[i]) {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL)
+	}
+	r.replayed[best] = true
+
+	return r.interactions[best], nil
+}
+
+// matchScore is 0 when recorded does not match req, and higher for closer lenient matches
+func matchScore(matching Matching, recorded, req RecordedRequest) int {
+	if recorded.Method != req.Method {
+		return 0
+	}
+	ru, err := url.Parse(recorded.URL)
+	if err != nil {
+		return 0
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return 0
+	}
+
+	sameQuery := sameValues(ru.Query(), u.Query())
+	sameBody := recorded.Body == req.Body
+	if matching == MatchStrict {
+		if ru.Scheme != u.Scheme || ru.Host != u.Host || ru.Path != u.Path || !sameQuery || !sameBody {
+			return 0
+		}
+		return 1
+	}
+
+	if ru.Path != u.Path {
+		return 0
+	}
+	score := 1
+	if sameQuery {
+		score += 2
+	}
+	if sameBody {
+		score++
+	}
+
+	return score
+}
+
+func sameValues(a, b url.Values) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, values := range a {
+		other := b[k]
+		if len(values) != len(other) {
+			return false
+		}
+		for i := range values {
+			if values[i] != other[i] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (r *Recorder) redactRequest(req *http.Request, body []byte) RecordedRequest {
+	return RecordedRequest{
+		Method: req.Method,
+		URL:    r.redact.url(req.URL),
+		Header: r.redact.header(req.Header),
+		Body:   r.redact.body(req.Header.Get("Content-Type"), body),
+	}
+}
+
+func (res RecordedResponse) response(req *http.Request) *http.Response {
+	header := res.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,137 @@
+package http
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordedServer() (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		if r.URL.Path == "/token" {
+			_, _ = w.Write([]byte(`{"access_token":"eyJ.secret.token","expires_in":86400,"id":12345678901234567890}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"` + r.URL.Query().Get("name") + `"}`))
+	}))
+	return srv, &calls
+}
+
+func record(t *testing.T, path, url string) {
+	recorder, err := NewRecorder(path, RecorderConfig{Mode: ModeRecord, Secrets: []string{"s3cr3t"}})
+	assert.NoError(t, err)
+	client := NewClientProvider(time.Second)
+	client.SetRecorder(recorder)
+
+	_, _, err = client.Request(url+"/token", "POST", nil, []byte(`{"client_id":"id","client_secret":"s3cr3t","audience":"api"}`), nil)
+	assert.NoError(t, err)
+	for _, name := range []string{"linux", "cncf"} {
+		_, body, err := client.Request(url+"/lookup", "GET", map[string]string{"Authorization": "Bearer eyJ.secret.token"}, nil, map[string]string{"name": name})
+		assert.NoError(t, err)
+		assert.Equal(t, `{"name":"`+name+`"}`, string(body))
+	}
+	assert.NoError(t, recorder.Save())
+}
+
+func TestRecorderRedactsSecrets(t *testing.T) {
+	srv, calls := recordedServer()
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "testdata", "orgs.json")
+
+	record(t, path, srv.URL)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	golden := string(data)
+	for _, secret := range []string{"s3cr3t", "eyJ.secret.token", "session=abc"} {
+		assert.False(t, strings.Contains(golden, secret), secret)
+	}
+	// the numbers are kept as written
+	assert.Contains(t, golden, "12345678901234567890")
+	assert.Contains(t, golden, `\"audience\":\"api\"`)
+}
+
+func TestRecorderReplay(t *testing.T) {
+	srv, calls := recordedServer()
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "orgs.json")
+	record(t, path, srv.URL)
+
+	recorder, err := NewRecorder(path, RecorderConfig{Matching: MatchStrict, Secrets: []string{"other"}})
+	assert.NoError(t, err)
+	client := NewClientProvider(time.Second)
+	client.SetRecorder(recorder)
+
+	// the secrets differ but are redacted before matching
+	status, body, err := client.Request(srv.URL+"/token", "POST", nil, []byte(`{"audience":"api","client_secret":"other","client_id":"id"}`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"access_token":"REDACTED"`)
+	_, body, err = client.Request(srv.URL+"/lookup", "GET", nil, nil, map[string]string{"name": "cncf"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"cncf"}`, string(body))
+
+	// strict matching replays each interaction once
+	_, _, err = client.Request(srv.URL+"/lookup", "GET", nil, nil, map[string]string{"name": "cncf"})
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+	_, _, err = client.Request(srv.URL+"/lookup", "GET", nil, nil, map[string]string{"name": "lf"})
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+
+	recorder, err = NewRecorder(path, RecorderConfig{Matching: MatchLenient})
+	assert.NoError(t, err)
+	client.SetRecorder(recorder)
+	for _, name := range []string{"cncf", "cncf", "lf"} {
+		_, body, err = client.Request("http://other.host/lookup", "GET", nil, nil, map[string]string{"name": name})
+		assert.NoError(t, err)
+		if name == "cncf" {
+			assert.Equal(t, `{"name":"cncf"}`, string(body))
+		}
+	}
+	_, _, err = client.Request(srv.URL+"/search", "GET", nil, nil, nil)
+	assert.True(t, errors.Is(err, ErrNoInteraction))
+
+	// nothing was sent while replaying
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRecorderUsesProviderTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"name":"linux"}`))
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder, err := NewRecorder(filepath.Join(dir, "tls.json"), RecorderConfig{Mode: ModeRecord})
+	assert.NoError(t, err)
+	client := NewClientProvider(time.Second)
+	// the test server certificate is only trusted by its own transport
+	client.SetTransport(srv.Client().Transport)
+	client.SetRecorder(recorder)
+
+	_, body, err := client.Request(srv.URL+"/lookup", "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"linux"}`, string(body))
+	assert.Len(t, recorder.Interactions(), 1)
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the secrets in the recorded interactions and the logs
+const Redacted = "REDACTED"
+
+var (
+	// DefaultRedactHeaders are always redacted
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+	// DefaultRedactFields are the query parameters, form and json fields always redacted
+	DefaultRedactFields = []string{"access_token", "refresh_token", "id_token", "client_secret", "password", "api_key", "token"}
+)
+
+// redactor hides the default and configured headers, fields and secret values
+type redactor struct {
+	headers map[string]bool
+	fields  map[string]bool
+	values  []string
+}
+
+func newRedactor(headers, fields, secrets []string) *redactor {
+	r := &redactor{headers: make(map[string]bool), fields: make(map[string]bool), values: secrets}
+	for _, list := range [][]string{DefaultRedactHeaders, headers} {
+		for _, h := range list {
+			r.headers[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+	for _, list := range [][]string{DefaultRedactFields, fields} {
+		for _, f := range list {
+			r.fields[strings.ToLower(f)] = true
+		}
+	}
+
+	return r
+}
+
+func (r *redactor) url(u *url.URL) string {
+	redacted := *u
+	q := redacted.Query()
+	for k := range q {
+		if r.fields[strings.ToLower(k)] {
+			q[k] = []string{Redacted}
+		}
+	}
+	if len(q) > 0 {
+		redacted.RawQuery = q.Encode()
+	}
+
+	return r.secrets(redacted.String())
+}
+
+func (r *redactor) header(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := make(http.Header, len(header))
+	for k, values := range header {
+		for _, v := range values {
+			if r.headers[http.CanonicalHeaderKey(k)] {
+				v = Redacted
+			}
+			redacted[k] = append(redacted[k], r.secrets(v))
+		}
+	}
+
+	return redacted
+}
+
+// body redacts the fields of json and form bodies, and the secrets of any body
+func (r *redactor) body(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var doc interface{}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			for k := range values {
+				if r.fields[strings.ToLower(k)] {
+					values[k] = []string{Redacted}
+				}
+			}
+			body = []byte(values.Encode())
+		}
+	} else if err := decodeJSONNumbers(body, &doc); err == nil {
+		// re-encoding also sorts the keys, so that equal documents match
+		if data, err := json.Marshal(r.json(doc)); err == nil {
+			body = data
+		}
+	}
+
+	return r.secrets(string(body))
+}
+
+// decodeJSONNumbers keeps the numbers as written, a float64 would round large ids
+func decodeJSONNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after the json document")
+	}
+	return nil
+}
+
+func (r *redactor) json(v interface{}) interface{} {
+	switch doc := v.(type) {
+	case map[string]interface{}:
+		for k, field := range doc {
+			if r.fields[strings.ToLower(k)] {
+				doc[k] = Redacted
+				continue
+			}
+			doc[k] = r.json(field)
+		}
+	case []interface{}:
+		for i, item := range doc {
+			doc[i] = r.json(item)
+		}
+	}
+
+	return v
+}
+
+func (r *redactor) secrets(s string) string {
+	for _, secret := range r.values {
+		if secret != "" {
+			s = strings.Replace(s, secret, Redacted, -1)
+			s = strings.Replace(s, url.QueryEscape(secret), Redacted, -1)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,244 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RequestOption configures a request sent with Send
+type RequestOption func(r *requestConfig) error
+
+// requestConfig is built by the options, it never references the caller's maps
+type requestConfig struct {
+	header      http.Header
+	query       url.Values
+	body        io.Reader
+	contentType string
+	timeout     time.Duration
+	expected    []int
+}
+
+// MultipartFile is a file part of a multipart body
+type MultipartFile struct {
+	Field    string
+	FileName string
+	Content  io.Reader
+}
+
+// WithHeader adds a header value, repeated calls with the same key send every value
+func WithHeader(key, value string) RequestOption {
+	return func(r *requestConfig) error {
+		r.header.Add(key, value)
+		return nil
+	}
+}
+
+// WithHeaders adds the header values
+func WithHeaders(header map[string]string) RequestOption {
+	return func(r *requestConfig) error {
+		for k, v := range header {
+			r.header.Add(k, v)
+		}
+		return nil
+	}
+}
+
+// WithQuery adds a query parameter value, repeated calls with the same key send every value
+func WithQuery(key, value string) RequestOption {
+	return func(r *requestConfig) error {
+		r.query.Add(key, value)
+		return nil
+	}
+}
+
+// WithQueryParams adds the query parameters values
+func WithQueryParams(params map[string]string) RequestOption {
+	return func(r *requestConfig) error {
+		for k, v := range params {
+			r.query.Add(k, v)
+		}
+		return nil
+	}
+}
+
+// WithBody sends body with the given content type, unless empty
+func WithBody(body io.Reader, contentType string) RequestOption {
+	return func(r *requestConfig) error {
+		r.body = body
+		r.contentType = contentType
+		return nil
+	}
+}
+
+// WithJSON sends v encoded as json
+func WithJSON(v interface{}) RequestOption {
+	return func(r *requestConfig) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		r.body = bytes.NewReader(data)
+		r.contentType = "application/json"
+		return nil
+	}
+}
+
+// WithForm sends the url encoded form values
+func WithForm(values url.Values) RequestOption {
+	return func(r *requestConfig) error {
+		r.body = bytes.NewReader([]byte(values.Encode()))
+		r.contentType = "application/x-www-form-urlencoded"
+		return nil
+	}
+}
+
+// WithMultipart sends a multipart/form-data body of fields and files
+func WithMultipart(fields map[string]string, files ...MultipartFile) RequestOption {
+	return func(r *requestConfig) error {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for k, v := range fields {
+			if err := w.WriteField(k, v); err != nil {
+				return err
+			}
+		}
+		for _, f := range files {
+			part, err := w.CreateFormFile(f.Field, f.FileName)
+			if err != nil {
+				return err
+			}
+			if _, err = io.Copy(part, f.Content); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		r.body = bytes.NewReader(buf.Bytes())
+		r.contentType = w.FormDataContentType()
+		return nil
+	}
+}
+
+// WithBasicAuth sets the basic authorization header
+func WithBasicAuth(username, password string) RequestOption {
+	return func(r *requestConfig) error {
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		r.header.Set("Authorization", req.Header.Get("Authorization"))
+		return nil
+	}
+}
+
+// WithBearerToken sets the bearer authorization header
+func WithBearerToken(token string) RequestOption {
+	return func(r *requestConfig) error {
+		r.header.Set("Authorization", fmt.Sprintf("%s %s", "Bearer", token))
+		return nil
+	}
+}
+
+// WithTimeout bounds the request, reading the response body included
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(r *requestConfig) error {
+		r.timeout = timeout
+		return nil
+	}
+}
+
+// ExpectStatus returns a *StatusError for any other response status
+func ExpectStatus(statuses ...int) RequestOption {
+	return func(r *requestConfig) error {
+		r.expected = append(r.expected, statuses...)
+		return nil
+	}
+}
+
+// Send sends a request built from opts, the caller must close the response
+func (h *ClientProvider) Send(ctx context.Context, method string, url string, opts ...RequestOption) (*StreamResponse, error) {
+	config := &requestConfig{header: http.Header{}, query: make(map[string][]string)}
+	for _, opt := range opts {
+		if err := opt(config); err != nil {
+			return nil, err
+		}
+	}
+
+	cancel := func() {}
+	if config.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, config.timeout)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, config.body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	for k, values := range config.header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if config.contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", config.contentType)
+	}
+	if len(config.query) > 0 {
+		q := req.URL.Query()
+		for k, values := range config.query {
+			for _, v := range values {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+
+	res, err := h.httpclient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stream := &StreamResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       &cancelBody{ReadCloser: res.Body, cancel: cancel},
+	}
+	if len(config.expected) > 0 && !containsStatus(config.expected, res.StatusCode) {
+		data, err := stream.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return nil, &StatusError{StatusCode: res.StatusCode, Body: data}
+	}
+
+	return stream, nil
+}
+
+// cancelBody releases the request timeout when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close ...
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echoRequest is what echoServer answers
+type echoRequest struct {
+	Method      string              `json:"method"`
+	Header      map[string][]string `json:"header"`
+	Query       map[string][]string `json:"query"`
+	ContentType string              `json:"content_type"`
+	Body        string              `json:"body"`
+	Form        map[string][]string `json:"form"`
+	Files       map[string]string   `json:"files"`
+}
+
+func echoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		echo := echoRequest{Method: r.Method, Header: r.Header, Query: r.URL.Query(), ContentType: r.Header.Get("Content-Type")}
+		if strings.HasPrefix(echo.ContentType, "multipart/form-data") {
+			_ = r.ParseMultipartForm(1 << 20)
+			echo.Form = r.MultipartForm.Value
+			echo.Files = map[string]string{}
+			for field, headers := range r.MultipartForm.File {
+				f, _ := headers[0].Open()
+				content, _ := ioutil.ReadAll(f)
+				echo.Files[field] = headers[0].Filename + ":" + string(content)
+			}
+		} else {
+			body, _ := ioutil.ReadAll(r.Body)
+			echo.Body = string(body)
+		}
+		_ = json.NewEncoder(w).Encode(echo)
+	}))
+}
+
+func send(t *testing.T, client *ClientProvider, url string, opts ...RequestOption) echoRequest {
+	res, err := client.Send(context.Background(), "POST", url, opts...)
+	assert.NoError(t, err)
+	var echo echoRequest
+	assert.NoError(t, res.DecodeJSON(&echo))
+	return echo
+}
+
+func TestSendHeadersAndQuery(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	client := NewClientProvider(time.Second)
+
+	echo := send(t, client, srv.URL+"?page=1",
+		WithHeader("Accept", "application/json"),
+		WithHeader("Accept", "text/csv"),
+		WithQuery("tag", "a"),
+		WithQuery("tag", "b"),
+		WithQueryParams(map[string]string{"size": "10"}),
+		WithBearerToken("token"))
+	assert.Equal(t, []string{"application/json", "text/csv"}, echo.Header["Accept"])
+	assert.Equal(t, []string{"a", "b"}, echo.Query["tag"])
+	assert.Equal(t, []string{"1"}, echo.Query["page"])
+	assert.Equal(t, []string{"10"}, echo.Query["size"])
+	assert.Equal(t, []string{"Bearer token"}, echo.Header["Authorization"])
+	assert.Equal(t, "", echo.ContentType)
+
+	echo = send(t, client, srv.URL, WithBasicAuth("user", "pass"))
+	assert.Equal(t, []string{"Basic dXNlcjpwYXNz"}, echo.Header["Authorization"])
+}
+
+func TestSendBodies(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	client := NewClientProvider(time.Second)
+
+	echo := send(t, client, srv.URL, WithJSON(map[string]string{"name": "CNCF"}))
+	assert.Equal(t, "application/json", echo.ContentType)
+	assert.Equal(t, `{"name":"CNCF"}`, echo.Body)
+
+	echo = send(t, client, srv.URL, WithForm(url.Values{"grant_type": {"client_credentials"}}))
+	assert.Equal(t, "application/x-www-form-urlencoded", echo.ContentType)
+	assert.Equal(t, "grant_type=client_credentials", echo.Body)
+
+	echo = send(t, client, srv.URL, WithMultipart(map[string]string{"project": "lfn"},
+		MultipartFile{Field: "file", FileName: "orgs.csv", Content: strings.NewReader("name\nCNCF\n")}))
+	assert.True(t, strings.HasPrefix(echo.ContentType, "multipart/form-data; boundary="))
+	assert.Equal(t, []string{"lfn"}, echo.Form["project"])
+	assert.Equal(t, "orgs.csv:name\nCNCF\n", echo.Files["file"])
+
+	// an explicit header wins over the body content type
+	echo = send(t, client, srv.URL, WithHeader("Content-Type", "application/vnd.api+json"), WithJSON(map[string]string{}))
+	assert.Equal(t, "application/vnd.api+json", echo.ContentType)
+}
+
+func TestSendTimeoutAndExpectStatus(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	client := NewClientProvider(time.Minute)
+
+	_, err := client.Send(context.Background(), "GET", srv.URL+"/slow", WithTimeout(20*time.Millisecond))
+	assert.Error(t, err)
+
+	_, err = client.Send(context.Background(), "GET", srv.URL, ExpectStatus(http.StatusCreated))
+	statusErr, ok := err.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusOK, statusErr.StatusCode)
+
+	res, err := client.Send(context.Background(), "GET", srv.URL, ExpectStatus(http.StatusOK, http.StatusCreated), WithTimeout(time.Second))
+	assert.NoError(t, err)
+	assert.NoError(t, res.Close())
+}
+
+func TestRequestDoesNotMutateCallerMaps(t *testing.T) {
+	srv := echoServer()
+	defer srv.Close()
+	client := NewClientProvider(time.Second)
+
+	header := map[string]string{"Content-Type": "application/json", "Authorization": "Bearer token"}
+	params := map[string]string{"name": "CNCF"}
+	status, body, err := client.Request(srv.URL, "GET", header, nil, params)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json", "Authorization": "Bearer token"}, header)
+	assert.Equal(t, map[string]string{"name": "CNCF"}, params)
+
+	var echo echoRequest
+	assert.NoError(t, json.Unmarshal(body, &echo))
+	assert.Equal(t, "application/json", echo.ContentType)
+	assert.Equal(t, []string{"CNCF"}, echo.Query["name"])
+
+	// a form content type given in the header map is kept
+	_, body, err = client.Request(srv.URL, "POST", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, []byte("a=b"), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &echo))
+	assert.Equal(t, "application/x-www-form-urlencoded", echo.ContentType)
+}
@@ -0,0 +1,221 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy configures the retries of failed requests.
+// A request is retried when its status is in RetryStatuses or Retryable accepts its error, and only when its method
+// is in RetryMethods or it has an Idempotency-Key header. The wait doubles from MinBackoff up to MaxBackoff with full
+// jitter, unless the response asks for another one with Retry-After or X-RateLimit-Reset. No retry is attempted
+// when it would end after MaxElapsed since the first attempt, zero meaning no limit.
+type RetryPolicy struct {
+	MaxAttempts   int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+	MaxElapsed    time.Duration
+	RetryStatuses []int
+	RetryMethods  []string
+	Retryable     func(err error) bool
+}
+
+// DefaultRetryPolicy retries idempotent requests 3 times on 429, 502, 503, 504 and network errors, within 2 minutes
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:   4,
+		MinBackoff:    500 * time.Millisecond,
+		MaxBackoff:    30 * time.Second,
+		MaxElapsed:    2 * time.Minute,
+		RetryStatuses: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryMethods:  []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete},
+		Retryable:     IsTemporaryError,
+	}
+}
+
+// IsTemporaryError accepts timeouts, refused or reset connections and unexpected EOFs
+func IsTemporaryError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// SetRetryPolicy retries the requests of the provider according to policy
+func (h *ClientProvider) SetRetryPolicy(policy RetryPolicy) {
+	h.retryPolicy = &policy
+	h.buildTransport()
+}
+
+// retryTransport is a RoundTripper retrying requests according to a RetryPolicy
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+	sleep  func(req *http.Request, d time.Duration) error
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newRetryTransport(next http.RoundTripper, policy RetryPolicy) *retryTransport {
+	defaults := DefaultRetryPolicy()
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaults.MaxAttempts
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = defaults.MinBackoff
+	}
+	if policy.MaxBackoff < policy.MinBackoff {
+		policy.MaxBackoff = policy.MinBackoff
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = func(error) bool { return false }
+	}
+
+	return &retryTransport{
+		next:   next,
+		policy: policy,
+		sleep:  sleepContext,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// RoundTrip ...
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	start := time.Now()
+	retryable := t.retryableMethod(req) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			// a RoundTripper must not modify the caller's request
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		res, err := next.RoundTrip(r)
+		if !retryable || attempt >= t.policy.MaxAttempts || !t.shouldRetry(res, err) {
+			return res, err
+		}
+
+		wait := t.backoff(attempt)
+		if res != nil {
+			if d, ok := retryAfter(res, time.Now()); ok {
+				wait = d
+			}
+		}
+		if t.policy.MaxElapsed > 0 && time.Since(start)+wait > t.policy.MaxElapsed {
+			return res, err
+		}
+		if res != nil {
+			// drain the body so that the connection can be reused
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		if err := t.sleep(req, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) retryableMethod(req *http.Request) bool {
+	if req.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	for _, m := range t.policy.RetryMethods {
+		if m == req.Method {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *retryTransport) shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return t.policy.Retryable(err)
+	}
+	for _, s := range t.policy.RetryStatuses {
+		if s == res.StatusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns a random wait up to MinBackoff * 2^(attempt-1), capped by MaxBackoff
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	max := t.policy.MinBackoff
+	for i := 1; i < attempt && max < t.policy.MaxBackoff; i++ {
+		max *= 2
+	}
+	if max > t.policy.MaxBackoff {
+		max = t.policy.MaxBackoff
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Duration(t.rand.Int63n(int64(max) + 1))
+}
+
+// retryAfter reads the wait asked by Retry-After, in seconds or as a date, or by an exhausted X-RateLimit-Reset
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	if v := res.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(v); err == nil {
+			if d := date.Sub(now); d > 0 {
+				return d, true
+			}
+			return 0, true
+		}
+	}
+
+	if res.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if d := time.Unix(reset, 0).Sub(now); d > 0 {
+				return d, true
+			}
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
+
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
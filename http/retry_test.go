@@ -0,0 +1,155 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MinBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+// failingServer answers the first failures requests with status and header, then 200 with the request body
+func failingServer(failures int32, status int, header map[string]string) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n <= failures {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	return srv, &calls
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	srv, calls := failingServer(2, http.StatusServiceUnavailable, nil)
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	client.SetRetryPolicy(testRetryPolicy())
+	status, body, err := client.Request(srv.URL, "PUT", nil, []byte(`{"a":1}`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	// the body is sent again on every attempt
+	assert.Equal(t, `{"a":1}`, string(body))
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	srv, calls := failingServer(10, http.StatusBadGateway, nil)
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	policy := testRetryPolicy()
+	policy.MaxAttempts = 2
+	client.SetRetryPolicy(policy)
+	status, _, err := client.Request(srv.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestRetryMethodPolicy(t *testing.T) {
+	srv, calls := failingServer(1, http.StatusServiceUnavailable, nil)
+	defer srv.Close()
+
+	client := NewClientProvider(time.Second)
+	client.SetRetryPolicy(testRetryPolicy())
+	status, _, err := client.Request(srv.URL, "POST", nil, []byte(`{}`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+	// a POST with an idempotency key is retried
+	status, _, err = client.Request(srv.URL, "POST", map[string]string{"Idempotency-Key": "k1"}, []byte(`{}`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestRetryAfter(t *testing.T) {
+	srv, calls := failingServer(1, http.StatusTooManyRequests, map[string]string{"Retry-After": "1"})
+	defer srv.Close()
+
+	client := NewClientProvider(5 * time.Second)
+	client.SetRetryPolicy(testRetryPolicy())
+	start := time.Now()
+	status, _, err := client.Request(srv.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// no retry when the asked wait exceeds the max elapsed time
+	srv2, calls2 := failingServer(1, http.StatusTooManyRequests, map[string]string{"Retry-After": "60"})
+	defer srv2.Close()
+	policy := testRetryPolicy()
+	policy.MaxElapsed = time.Second
+	client.SetRetryPolicy(policy)
+	status, _, err = client.Request(srv2.URL, "GET", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls2))
+}
+
+func TestRetryAfterHeaders(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header map[string]string
+		wait   time.Duration
+		ok     bool
+	}{
+		{"seconds", map[string]string{"Retry-After": "30"}, 30 * time.Second, true},
+		{"date", map[string]string{"Retry-After": now.Add(time.Minute).Format(http.TimeFormat)}, time.Minute, true},
+		{"rate limit reset", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(now.Add(10*time.Second).Unix(), 10)}, 10 * time.Second, true},
+		{"rate limit not exhausted", map[string]string{"X-RateLimit-Remaining": "10", "X-RateLimit-Reset": strconv.FormatInt(now.Unix(), 10)}, 0, false},
+		{"none", nil, 0, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := &http.Response{Header: http.Header{}}
+			for k, v := range c.header {
+				res.Header.Set(k, v)
+			}
+			wait, ok := retryAfter(res, now)
+			assert.Equal(t, c.ok, ok)
+			assert.Equal(t, c.wait, wait)
+		})
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	srv, _ := failingServer(0, 0, nil)
+	url := srv.URL
+	srv.Close()
+
+	var sleeps int32
+	client := NewClientProvider(time.Second)
+	client.SetRetryPolicy(testRetryPolicy())
+	rt := client.httpclient.Transport.(*retryTransport)
+	rt.sleep = func(req *http.Request, d time.Duration) error {
+		atomic.AddInt32(&sleeps, 1)
+		return nil
+	}
+
+	_, _, err := client.Request(url, "GET", nil, nil, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&sleeps))
+}
@@ -0,0 +1,217 @@
+package lock
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ESClientProvider used in connecting to ES server
+type ESClientProvider interface {
+	CreateDocumentAndWait(index, documentID string, body []byte) ([]byte, error)
+	Search(index string, query map[string]interface{}) ([]byte, error)
+	DeleteDocumentByQuery(index string, query map[string]interface{}) ([]byte, error)
+	UpdateDocument(index string, id string, body interface{}) ([]byte, error)
+}
+
+// ESLocker keeps leases as documents of an ES index, one document per lock name.
+// Documents are created with create-only semantics so only one owner can hold a lease,
+// expired leases are deleted by the next owner trying to acquire them.
+// Leases are looked up by search, so they are created waiting for a refresh to be visible right away.
+type ESLocker struct {
+	esClient ESClientProvider
+	index    string
+	owner    string
+}
+
+// esLease is the lease document, dates are epoch milliseconds so they can be compared in queries whatever the index mapping
+type esLease struct {
+	Name       string `json:"name"`
+	Owner      string `json:"owner"`
+	AcquiredAt int64  `json:"acquired_at"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+type esDeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+type esLeaseSchema struct {
+	Hits struct {
+		Hits []struct {
+			Source esLease `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// NewESLocker creates a locker storing leases in index and acquiring them as owner, owner must be unique per process
+func NewESLocker(esClient ESClientProvider, index string, owner string) *ESLocker {
+	return &ESLocker{
+		esClient: esClient,
+		index:    index,
+		owner:    owner,
+	}
+}
+
+// Acquire ...
+func (l *ESLocker) Acquire(name string, ttl time.Duration) (*Lease, error) {
+	lease, err := l.create(name, ttl)
+	if err == nil || err != ErrLocked {
+		return lease, err
+	}
+
+	current, err := l.get(name)
+	if err != nil {
+		return nil, err
+	}
+	// a lease just created by another owner may not be searchable yet
+	if current == nil || !current.Expired(time.Now().UTC()) {
+		return nil, ErrLocked
+	}
+
+	// remove the expired lease unless it was renewed or replaced meanwhile, then try again
+	_, err = l.esClient.DeleteDocumentByQuery(l.index, leaseQuery(name, current.AcquiredAt, true))
+	if err != nil {
+		return nil, err
+	}
+
+	return l.create(name, ttl)
+}
+
+// Renew extends a lease held by this owner
+func (l *ESLocker) Renew(lease *Lease, ttl time.Duration) (*Lease, error) {
+	current, err := l.get(lease.Name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if current == nil || lease.Owner != l.owner || !sameLease(current, lease) || current.Expired(now) {
+		return nil, ErrNotOwner
+	}
+
+	renewed := *current
+	renewed.ExpiresAt = now.Add(ttl)
+	_, err = l.esClient.UpdateDocument(l.index, lease.Name, map[string]interface{}{
+		"expires_at": millis(renewed.ExpiresAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &renewed, nil
+}
+
+// Release removes a lease held by this owner
+func (l *ESLocker) Release(lease *Lease) error {
+	current, err := l.get(lease.Name)
+	if err != nil {
+		return err
+	}
+	if current == nil || lease.Owner != l.owner || !sameLease(current, lease) {
+		return ErrNotOwner
+	}
+
+	res, err := l.esClient.DeleteDocumentByQuery(l.index, leaseQuery(lease.Name, current.AcquiredAt, false))
+	if err != nil {
+		return err
+	}
+
+	var deleted esDeleteResponse
+	if err = json.Unmarshal(res, &deleted); err != nil {
+		return err
+	}
+	// the lease expired and was taken over since it was read
+	if deleted.Deleted != 1 {
+		return ErrNotOwner
+	}
+
+	return nil
+}
+
+func (l *ESLocker) create(name string, ttl time.Duration) (*Lease, error) {
+	now := time.Now().UTC()
+	// truncate to the stored precision so leases read back compare equal
+	now = time.Unix(0, millis(now)*int64(time.Millisecond)).UTC()
+	lease := &Lease{Name: name, Owner: l.owner, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	body, err := json.Marshal(esLease{
+		Name:       name,
+		Owner:      l.owner,
+		AcquiredAt: millis(lease.AcquiredAt),
+		ExpiresAt:  millis(lease.ExpiresAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = l.esClient.CreateDocumentAndWait(l.index, name, body)
+	if err != nil {
+		if strings.Contains(err.Error(), "version_conflict_engine_exception") {
+			return nil, ErrLocked
+		}
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+func (l *ESLocker) get(name string) (*Lease, error) {
+	res, err := l.esClient.Search(l.index, map[string]interface{}{
+		"size": 1,
+		"query": map[string]interface{}{
+			"ids": map[string]interface{}{
+				"values": []string{name},
+			},
+		},
+	})
+	if err != nil {
+		if err.Error() == "index doesn't exist" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var e esLeaseSchema
+	if err = json.Unmarshal(res, &e); err != nil {
+		return nil, err
+	}
+	if len(e.Hits.Hits) == 0 {
+		return nil, nil
+	}
+
+	doc := e.Hits.Hits[0].Source
+	return &Lease{
+		Name:       name,
+		Owner:      doc.Owner,
+		AcquiredAt: time.Unix(0, doc.AcquiredAt*int64(time.Millisecond)).UTC(),
+		ExpiresAt:  time.Unix(0, doc.ExpiresAt*int64(time.Millisecond)).UTC(),
+	}, nil
+}
+
+// leaseQuery matches the lease document name acquired at acquiredAt, only when expired if onlyExpired is set
+func leaseQuery(name string, acquiredAt time.Time, onlyExpired bool) map[string]interface{} {
+	filter := []map[string]interface{}{
+		{"ids": map[string]interface{}{"values": []string{name}}},
+		{"term": map[string]interface{}{"acquired_at": millis(acquiredAt)}},
+	}
+	if onlyExpired {
+		filter = append(filter, map[string]interface{}{
+			"range": map[string]interface{}{"expires_at": map[string]interface{}{"lte": millis(time.Now().UTC())}},
+		})
+	}
+
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filter,
+			},
+		},
+	}
+}
+
+func sameLease(a, b *Lease) bool {
+	return a.Owner == b.Owner && a.AcquiredAt.Equal(b.AcquiredAt)
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
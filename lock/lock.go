@@ -0,0 +1,105 @@
+package lock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned when the lock is held by another owner and has not expired
+var ErrLocked = errors.New("lock is held by another owner")
+
+// ErrNotOwner is returned when renewing or releasing a lease that is no longer held
+var ErrNotOwner = errors.New("lease is not held by this owner")
+
+// Lease is a lock held by Owner until ExpiresAt
+type Lease struct {
+	Name       string    `json:"name"`
+	Owner      string    `json:"owner"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the lease expired at t
+func (l *Lease) Expired(t time.Time) bool {
+	return !t.Before(l.ExpiresAt)
+}
+
+// Locker acquires named leases shared between processes.
+// Acquire returns ErrLocked when another owner holds an unexpired lease on name.
+type Locker interface {
+	Acquire(name string, ttl time.Duration) (*Lease, error)
+	Renew(lease *Lease, ttl time.Duration) (*Lease, error)
+	Release(lease *Lease) error
+}
+
+// MemoryLocker is a Locker local to the process, for tests and single instance services
+type MemoryLocker struct {
+	owner  string
+	mu     *sync.Mutex
+	leases map[string]*Lease
+}
+
+// NewMemoryLocker creates a local locker acquiring leases as owner
+func NewMemoryLocker(owner string) *MemoryLocker {
+	return &MemoryLocker{
+		owner:  owner,
+		mu:     &sync.Mutex{},
+		leases: make(map[string]*Lease),
+	}
+}
+
+// WithOwner returns a locker sharing the same leases but acquiring them as another owner, to simulate several processes
+func (m *MemoryLocker) WithOwner(owner string) *MemoryLocker {
+	return &MemoryLocker{
+		owner:  owner,
+		mu:     m.mu,
+		leases: m.leases,
+	}
+}
+
+// Acquire ...
+func (m *MemoryLocker) Acquire(name string, ttl time.Duration) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	if current, ok := m.leases[name]; ok && !current.Expired(now) {
+		return nil, ErrLocked
+	}
+	lease := &Lease{Name: name, Owner: m.owner, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	m.leases[name] = lease
+
+	copied := *lease
+	return &copied, nil
+}
+
+// Renew ...
+func (m *MemoryLocker) Renew(lease *Lease, ttl time.Duration) (*Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	current, ok := m.leases[lease.Name]
+	if !ok || lease.Owner != m.owner || current.Owner != lease.Owner || !current.AcquiredAt.Equal(lease.AcquiredAt) || current.Expired(now) {
+		return nil, ErrNotOwner
+	}
+	current.ExpiresAt = now.Add(ttl)
+
+	copied := *current
+	return &copied, nil
+}
+
+// Release ...
+func (m *MemoryLocker) Release(lease *Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.leases[lease.Name]
+	if !ok || lease.Owner != m.owner || current.Owner != lease.Owner || !current.AcquiredAt.Equal(lease.AcquiredAt) {
+		return ErrNotOwner
+	}
+	delete(m.leases, lease.Name)
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testLocker(t *testing.T, first, second Locker) {
+	lease, err := first.Acquire("cron-leader", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, "cron-leader", lease.Name)
+
+	_, err = second.Acquire("cron-leader", time.Minute)
+	assert.Equal(t, ErrLocked, err)
+
+	other, err := second.Acquire("other", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrNotOwner, first.Release(other))
+
+	renewed, err := first.Renew(lease, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, renewed.ExpiresAt.After(lease.ExpiresAt))
+
+	assert.NoError(t, first.Release(renewed))
+	lease, err = second.Acquire("cron-leader", time.Millisecond)
+	assert.NoError(t, err)
+
+	// expired leases can be taken over
+	time.Sleep(5 * time.Millisecond)
+	_, err = first.Acquire("cron-leader", time.Minute)
+	assert.NoError(t, err)
+	_, err = second.Renew(lease, time.Minute)
+	assert.Equal(t, ErrNotOwner, err)
+}
+
+func TestMemoryLocker(t *testing.T) {
+	first := NewMemoryLocker("pod-1")
+	testLocker(t, first, first.WithOwner("pod-2"))
+}
+
+func TestESLocker(t *testing.T) {
+	es := newFakeES()
+	testLocker(t, NewESLocker(es, "locks", "pod-1"), NewESLocker(es, "locks", "pod-2"))
+}
+
+func TestESLockerReleaseTakenOver(t *testing.T) {
+	es := newFakeES()
+	locker := NewESLocker(es, "locks", "pod-1")
+	lease, err := locker.Acquire("cron-leader", time.Minute)
+	assert.NoError(t, err)
+
+	// the lease is replaced by another owner, the searches do not see it yet
+	es.mu.Lock()
+	doc := es.docs["cron-leader"]
+	doc.Owner, doc.AcquiredAt = "pod-2", doc.AcquiredAt+1
+	es.docs["cron-leader"] = doc
+	es.mu.Unlock()
+
+	assert.Equal(t, ErrNotOwner, locker.Release(lease))
+	assert.Equal(t, "pod-2", es.docs["cron-leader"].Owner)
+}
+
+// fakeES implements the document operations used by ESLocker on an in memory index.
+// Like ES the searches are near real time, they only see the documents as of the last refresh.
+type fakeES struct {
+	mu      sync.Mutex
+	docs    map[string]esLease
+	visible map[string]esLease
+}
+
+func newFakeES() *fakeES {
+	return &fakeES{docs: make(map[string]esLease), visible: make(map[string]esLease)}
+}
+
+func (f *fakeES) refresh() {
+	f.visible = make(map[string]esLease, len(f.docs))
+	for id, doc := range f.docs {
+		f.visible[id] = doc
+	}
+}
+
+func (f *fakeES) CreateDocumentAndWait(index, documentID string, body []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.docs[documentID]; ok {
+		return nil, errors.New("[409 Conflict] version_conflict_engine_exception: document already exists")
+	}
+	var doc esLease
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	f.docs[documentID] = doc
+	f.refresh()
+	return []byte(`{}`), nil
+}
+
+func (f *fakeES) Search(index string, query map[string]interface{}) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := query["query"].(map[string]interface{})["ids"].(map[string]interface{})["values"].([]string)[0]
+	var res esLeaseSchema
+	if doc, ok := f.visible[id]; ok {
+		res.Hits.Hits = append(res.Hits.Hits, struct {
+			Source esLease `json:"_source"`
+		}{doc})
+	}
+	return json.Marshal(res)
+}
+
+// DeleteDocumentByQuery searches the visible documents, then deletes those not changed since
+func (f *fakeES) DeleteDocumentByQuery(index string, query map[string]interface{}) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filter := query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]map[string]interface{})
+	id := filter[0]["ids"].(map[string]interface{})["values"].([]string)[0]
+	acquiredAt := filter[1]["term"].(map[string]interface{})["acquired_at"].(int64)
+	doc, ok := f.visible[id]
+	if !ok || doc.AcquiredAt != acquiredAt {
+		return []byte(`{"deleted":0}`), nil
+	}
+	if len(filter) > 2 {
+		lte := filter[2]["range"].(map[string]interface{})["expires_at"].(map[string]interface{})["lte"].(int64)
+		if doc.ExpiresAt > lte {
+			return []byte(`{"deleted":0}`), nil
+		}
+	}
+	if current, ok := f.docs[id]; !ok || current.AcquiredAt != doc.AcquiredAt || current.Owner != doc.Owner {
+		// version conflict
+		return []byte(`{"deleted":0}`), nil
+	}
+	delete(f.docs, id)
+	return []byte(`{"deleted":1}`), nil
+}
+
+func (f *fakeES) UpdateDocument(index string, id string, body interface{}) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	doc := f.docs[id]
+	doc.ExpiresAt = body.(map[string]interface{})["expires_at"].(int64)
+	f.docs[id] = doc
+	// the ES client updates with refresh=true
+	f.refresh()
+	return []byte(`{}`), nil
+}
@@ -0,0 +1,185 @@
+package orgs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/http"
+)
+
+// GetOrganization returns the organization with id, or ErrOrganizationNotFound
+func (o *Org) GetOrganization(ctx context.Context, id string) (*Organization, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+
+	var response Organization
+	if err := o.call(ctx, "GetOrganization", nethttp.MethodGet, "/orgs/"+url.PathEscape(id), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// lookupOrganization is LookupOrganization bound to ctx, the failed requests being errors rather than an empty organization.
+// A name unknown to the service is ErrOrganizationNotFound.
+func (o *Org) lookupOrganization(ctx context.Context, name string) (*Organization, error) {
+	var response Organization
+	if err := o.call(ctx, "LookupOrganization", nethttp.MethodGet, "/lookup?name="+url.QueryEscape(name), nil, &response); err != nil {
+		return nil, err
+	}
+	// the service answers an empty organization for the unknown names
+	if response.ID == "" {
+		return nil, fmt.Errorf("LookupOrganization: %w", ErrOrganizationNotFound)
+	}
+	return &response, nil
+}
+
+// CreateOrganization creates org and returns it with its id, or ErrOrganizationConflict when it already exists
+func (o *Org) CreateOrganization(ctx context.Context, org *Organization) (*Organization, error) {
+	if org == nil || strings.TrimSpace(org.Name) == "" {
+		return nil, errors.New("CreateOrganization: organization name is empty")
+	}
+
+	var response Organization
+	if err := o.call(ctx, "CreateOrganization", nethttp.MethodPost, "/orgs", org, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateOrganization replaces the organization with the id of org and returns it
+func (o *Org) UpdateOrganization(ctx context.Context, org *Organization) (*Organization, error) {
+	if org == nil || org.ID == "" {
+		return nil, ErrEmptyID
+	}
+
+	var response Organization
+	if err := o.call(ctx, "UpdateOrganization", nethttp.MethodPut, "/orgs/"+url.PathEscape(org.ID), org, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DeleteOrganization deletes the organization with id
+func (o *Org) DeleteOrganization(ctx context.Context, id string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+	return o.call(ctx, "DeleteOrganization", nethttp.MethodDelete, "/orgs/"+url.PathEscape(id), nil, nil)
+}
+
+// MergeOrganizations merges the organization fromID into toID, with its domains, and returns the merged organization
+func (o *Org) MergeOrganizations(ctx context.Context, fromID, toID string) (*Organization, error) {
+	if fromID == "" || toID == "" {
+		return nil, ErrEmptyID
+	}
+	if fromID == toID {
+		return nil, errors.New("MergeOrganizations: cannot merge an organization into itself")
+	}
+
+	var response Organization
+	endpoint := "/orgs/" + url.PathEscape(toID) + "/merge/" + url.PathEscape(fromID)
+	if err := o.call(ctx, "MergeOrganizations", nethttp.MethodPost, endpoint, nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListDomains returns the domains of the organization with id
+func (o *Org) ListDomains(ctx context.Context, id string) ([]string, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+
+	var response DomainsResponse
+	if err := o.call(ctx, "ListDomains", nethttp.MethodGet, "/orgs/"+url.PathEscape(id)+"/domains", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Domains, nil
+}
+
+// AddDomain adds domain to the organization with id, or returns ErrOrganizationConflict when another organization has it
+func (o *Org) AddDomain(ctx context.Context, id, domain string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return errors.New("AddDomain: domain is empty")
+	}
+
+	return o.call(ctx, "AddDomain", nethttp.MethodPost, "/orgs/"+url.PathEscape(id)+"/domains", domainRequest{Domain: domain}, nil)
+}
+
+// RemoveDomain removes domain from the organization with id
+func (o *Org) RemoveDomain(ctx context.Context, id, domain string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return errors.New("RemoveDomain: domain is empty")
+	}
+
+	endpoint := "/orgs/" + url.PathEscape(id) + "/domains/" + url.PathEscape(domain)
+	return o.call(ctx, "RemoveDomain", nethttp.MethodDelete, endpoint, nil, nil)
+}
+
+// call sends in as json to the organization service path and decodes the response into out, unless nil.
+// The 404 and 409 statuses are returned as ErrOrganizationNotFound and ErrOrganizationConflict, other errors as a *ResponseError.
+func (o *Org) call(ctx context.Context, operation, method, path string, in interface{}, out interface{}) error {
+	headers, err := http.BearerHeader(o.auth0Client)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	var body []byte
+	if in != nil {
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	statusCode, res, err := o.request(ctx, strings.TrimSpace(o.OrgBaseURL+path), method, headers, body)
+	if err != nil {
+		log.Printf("%s: request failed: %v", operation, err)
+		return err
+	}
+
+	switch {
+	case statusCode == nethttp.StatusNotFound:
+		return fmt.Errorf("%s: %w", operation, ErrOrganizationNotFound)
+	case statusCode == nethttp.StatusConflict:
+		return fmt.Errorf("%s: %w", operation, ErrOrganizationConflict)
+	case statusCode < 200 || statusCode > 299:
+		return &ResponseError{Operation: operation, StatusCode: statusCode, Message: string(res)}
+	}
+
+	if out == nil || len(res) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(res, out); err != nil {
+		log.Printf("%s: failed to unmarshal the response: %v", operation, err)
+		return err
+	}
+
+	return nil
+}
+
+// request sends the request bound to ctx when the http client supports it
+func (o *Org) request(ctx context.Context, endpoint, method string, headers map[string]string, body []byte) (int, []byte, error) {
+	if client, ok := o.httpClient.(ContextHTTPClientProvider); ok {
+		return client.RequestContext(ctx, endpoint, method, headers, body, nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
+	}
+	return o.httpClient.Request(endpoint, method, headers, body, nil)
+}
@@ -0,0 +1,164 @@
+package orgs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/orgs/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newCrudOrg() (*Org, *mocks.HTTPClientProvider) {
+	httpClient := &mocks.HTTPClientProvider{}
+	auth0Client := &mocks.Auth0ClientProvider{}
+	auth0Client.On("GetToken").Return(token, nil)
+	return &Org{OrgBaseURL: "https://orgs.lfx.dev", httpClient: httpClient, auth0Client: auth0Client}, httpClient
+}
+
+func bearer() map[string]string {
+	return map[string]string{"Authorization": fmt.Sprintf("%s %s", "Bearer", token)}
+}
+
+func TestOrganizationCRUD(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	created := Organization{ID: "v03fs-3", Name: "Linux Foundation", Domains: []string{"linuxfoundation.org"}}
+	createdBytes, _ := json.Marshal(created)
+	body, _ := json.Marshal(&Organization{Name: "Linux Foundation", Domains: []string{"linuxfoundation.org"}})
+
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs", "POST", bearer(), body, map[string]string(nil)).Return(201, createdBytes, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/v03fs-3", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, createdBytes, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/missing", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(404, []byte(`{"message":"not found"}`), nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/v03fs-3", "PUT", bearer(), mock.Anything, map[string]string(nil)).Return(OKStatus, createdBytes, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/v03fs-3", "DELETE", bearer(), []byte(nil), map[string]string(nil)).Return(204, []byte(nil), nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/v03fs-3/merge/v03fs-5", "POST", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, createdBytes, nil)
+
+	res, err := org.CreateOrganization(ctx, &Organization{Name: "Linux Foundation", Domains: []string{"linuxfoundation.org"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-3", res.ID)
+
+	res, err = org.GetOrganization(ctx, "v03fs-3")
+	assert.NoError(t, err)
+	assert.Equal(t, created, *res)
+	_, err = org.GetOrganization(ctx, "missing")
+	assert.True(t, errors.Is(err, ErrOrganizationNotFound))
+	_, err = org.GetOrganization(ctx, "")
+	assert.Equal(t, ErrEmptyID, err)
+
+	res, err = org.UpdateOrganization(ctx, &created)
+	assert.NoError(t, err)
+	assert.Equal(t, "Linux Foundation", res.Name)
+	assert.NoError(t, org.DeleteOrganization(ctx, "v03fs-3"))
+
+	res, err = org.MergeOrganizations(ctx, "v03fs-5", "v03fs-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-3", res.ID)
+	_, err = org.MergeOrganizations(ctx, "v03fs-3", "v03fs-3")
+	assert.Error(t, err)
+}
+
+func TestOrganizationDomains(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	endpoint := "https://orgs.lfx.dev/orgs/v03fs-3/domains"
+
+	httpClient.On("RequestContext", ctx, endpoint, "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, []byte(`{"Domains":["linuxfoundation.org","lfx.dev"]}`), nil)
+	httpClient.On("RequestContext", ctx, endpoint, "POST", bearer(), []byte(`{"Domain":"lfx.dev"}`), map[string]string(nil)).Return(409, []byte(`{"message":"domain exists"}`), nil)
+	httpClient.On("RequestContext", ctx, endpoint, "POST", bearer(), []byte(`{"Domain":"cncf.io"}`), map[string]string(nil)).Return(500, []byte(`{"message":"internal error"}`), nil)
+	httpClient.On("RequestContext", ctx, endpoint+"/lfx.dev", "DELETE", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, []byte(nil), nil)
+
+	domains, err := org.ListDomains(ctx, "v03fs-3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"linuxfoundation.org", "lfx.dev"}, domains)
+
+	err = org.AddDomain(ctx, "v03fs-3", " LFX.dev ")
+	assert.True(t, errors.Is(err, ErrOrganizationConflict))
+	err = org.AddDomain(ctx, "v03fs-3", "cncf.io")
+	var resErr *ResponseError
+	assert.True(t, errors.As(err, &resErr))
+	assert.Equal(t, 500, resErr.StatusCode)
+	assert.Equal(t, "AddDomain", resErr.Operation)
+
+	assert.NoError(t, org.RemoveDomain(ctx, "v03fs-3", "lfx.dev"))
+	assert.Error(t, org.RemoveDomain(ctx, "v03fs-3", ""))
+}
+
+func TestOrganizationsIterator(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	page := func(total, offset int, names ...string) []byte {
+		res := SearchOrganizationResponse{}
+		for _, name := range names {
+			res.Data = append(res.Data, Organization{ID: name, Name: name})
+		}
+		res.Metadata.Offset = offset
+		res.Metadata.PageSize = 2
+		res.Metadata.TotalSize = total
+		data, _ := json.Marshal(res)
+		return data
+	}
+	endpoint := "https://orgs.lfx.dev/orgs/search?name=linux&offset=%d&pageSize=2"
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, 0), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, page(5, 0, "a", "b"), nil)
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, 1), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, page(5, 1, "c", "d"), nil)
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, 2), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, page(5, 2, "e"), nil)
+
+	it := org.Organizations(ctx, "linux", 2)
+	all, err := it.All()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, it.Total())
+	var names []string
+	for _, o := range all {
+		names = append(names, o.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 3)
+
+	// without TotalSize the walk goes on until a short page
+	endpoint = "https://orgs.lfx.dev/orgs/search?name=cncf&offset=%d&pageSize=2"
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, 0), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, page(0, 0, "a", "b"), nil)
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, 1), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, page(0, 1, "c", "d"), nil)
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, 2), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, page(0, 2, "e"), nil)
+	all, err = org.Organizations(ctx, "cncf", 2).All()
+	assert.NoError(t, err)
+	assert.Len(t, all, 5)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 6)
+
+	// the errors stop the iteration
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/search?name=lf&offset=0&pageSize=2", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(503, []byte("unavailable"), nil)
+	it = org.Organizations(ctx, "lf", 2)
+	assert.False(t, it.Next())
+	var resErr *ResponseError
+	assert.True(t, errors.As(it.Err(), &resErr))
+}
+
+// requestOnlyClient is a HTTPClientProvider without RequestContext
+type requestOnlyClient struct {
+	client *mocks.HTTPClientProvider
+}
+
+func (c *requestOnlyClient) Request(url string, method string, header map[string]string, body []byte, params map[string]string) (int, []byte, error) {
+	return c.client.Request(url, method, header, body, params)
+}
+
+func TestCallWithoutContextClient(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	org.httpClient = &requestOnlyClient{client: httpClient}
+	created, _ := json.Marshal(Organization{ID: "v03fs-3", Name: "Linux Foundation"})
+	httpClient.On("Request", "https://orgs.lfx.dev/orgs/v03fs-3", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, created, nil)
+
+	res, err := org.GetOrganization(context.Background(), "v03fs-3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Linux Foundation", res.Name)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 0)
+
+	// the context is still checked before sending the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = org.GetOrganization(ctx, "v03fs-3")
+	assert.Equal(t, context.Canceled, err)
+	httpClient.AssertNumberOfCalls(t, "Request", 1)
+}
@@ -0,0 +1,379 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultDirectoryTTL is the refresh interval of a directory
+	DefaultDirectoryTTL = time.Hour
+	// DefaultNegativeTTL is how long a name unknown to the organization service is not asked again
+	DefaultNegativeTTL = 10 * time.Minute
+)
+
+// DirectoryConfig configures an OrgDirectory, the zero values meaning the defaults
+type DirectoryConfig struct {
+	// TTL is the interval of the background refreshes
+	TTL time.Duration
+	// NegativeTTL is how long the names and ids not found are remembered
+	NegativeTTL time.Duration
+	PageSize    int
+	// Query is the search name listing the organizations to preload, required by Preload and Start.
+	// The organization service has no listing of every organization, an empty name is not searched.
+	Query string
+	// LoadDomains makes Preload ask the domains of the organizations found without any, one request each,
+	// for the search results may not carry the domains ByDomain looks up
+	LoadDomains bool
+}
+
+// DirectoryStats counts the lookups of a directory
+type DirectoryStats struct {
+	Organizations int
+	Hits          int64
+	Misses        int64
+	LoadedAt      time.Time
+}
+
+// OrgDirectory serves the organizations from an in-memory index by id, normalized name and domain.
+// The index is filled by Preload and refreshed every TTL once started, a lookup missing it asks the
+// organization service and indexes the answer.
+type OrgDirectory struct {
+	org    *Org
+	config DirectoryConfig
+	now    func() time.Time
+
+	mu       sync.RWMutex
+	index    *orgIndex
+	notFound map[string]time.Time
+	loadedAt time.Time
+	// loading counts the preloads in progress, the organizations added meanwhile are kept in added
+	// to be indexed again once the preloaded index replaces the current one
+	loading int
+	added   []addedOrganization
+
+	hits   int64
+	misses int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// orgIndex ...
+type orgIndex struct {
+	byID     map[string]*Organization
+	byName   map[string]*Organization
+	byDomain map[string]*Organization
+}
+
+// addedOrganization is an organization indexed after a lookup, with the name it was looked up by
+type addedOrganization struct {
+	org   *Organization
+	alias string
+}
+
+func newOrgIndex() *orgIndex {
+	return &orgIndex{
+		byID:     make(map[string]*Organization),
+		byName:   make(map[string]*Organization),
+		byDomain: make(map[string]*Organization),
+	}
+}
+
+func (i *orgIndex) add(org *Organization, alias string) {
+	if org.ID != "" {
+		i.byID[org.ID] = org
+	}
+	if name := NormalizeName(org.Name); name != "" {
+		i.byName[name] = org
+	}
+	if alias != "" {
+		i.byName[alias] = org
+	}
+	for _, domain := range org.Domains {
+		if domain = normalizeDomain(domain); domain != "" {
+			i.byDomain[domain] = org
+		}
+	}
+}
+
+// NewOrgDirectory creates an empty directory of the organizations of org
+func NewOrgDirectory(org *Org, config DirectoryConfig) *OrgDirectory {
+	if config.TTL <= 0 {
+		config.TTL = DefaultDirectoryTTL
+	}
+	if config.NegativeTTL <= 0 {
+		config.NegativeTTL = DefaultNegativeTTL
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = DefaultPageSize
+	}
+
+	return &OrgDirectory{
+		org:      org,
+		config:   config,
+		now:      time.Now,
+		index:    newOrgIndex(),
+		notFound: make(map[string]time.Time),
+	}
+}
+
+// Preload pages through the organizations and replaces the index with them.
+// The organizations looked up while it runs are kept in the new index.
+func (d *OrgDirectory) Preload(ctx context.Context) error {
+	if strings.TrimSpace(d.config.Query) == "" {
+		return errors.New("Preload: the directory query is empty")
+	}
+
+	d.mu.Lock()
+	d.loading++
+	d.mu.Unlock()
+
+	index := newOrgIndex()
+	err := d.load(ctx, index)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loading--
+	if err == nil {
+		for _, added := range d.added {
+			index.add(added.org, added.alias)
+		}
+		d.index = index
+		d.notFound = make(map[string]time.Time)
+		d.loadedAt = d.now()
+	}
+	if d.loading == 0 {
+		d.added = nil
+	}
+	if err != nil {
+		return fmt.Errorf("Preload: %w", err)
+	}
+
+	return nil
+}
+
+// load pages through the organizations of the query into index
+func (d *OrgDirectory) load(ctx context.Context, index *orgIndex) error {
+	it := d.org.Organizations(ctx, d.config.Query, d.config.PageSize)
+	for it.Next() {
+		org := it.Organization()
+		if d.config.LoadDomains && len(org.Domains) == 0 {
+			domains, err := d.org.ListDomains(ctx, org.ID)
+			if err != nil && !errors.Is(err, ErrOrganizationNotFound) {
+				return err
+			}
+			org.Domains = domains
+		}
+		index.add(&org, "")
+	}
+
+	return it.Err()
+}
+
+// Start preloads the directory, then refreshes it every TTL in the background until Stop or the end of ctx.
+// A failed refresh keeps the previous index.
+func (d *OrgDirectory) Start(ctx context.Context) error {
+	d.mu.Lock()
+	if d.stop != nil {
+		d.mu.Unlock()
+		return errors.New("Start: the directory is already started")
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	d.stop, d.done = stop, done
+	d.mu.Unlock()
+
+	if err := d.Preload(ctx); err != nil {
+		d.mu.Lock()
+		d.stop, d.done = nil, nil
+		d.mu.Unlock()
+		return err
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(d.config.TTL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.Preload(ctx); err != nil {
+					log.Println("OrgDirectory: refresh failed: ", err)
+				}
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop ends the background refreshes
+func (d *OrgDirectory) Stop() {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// ByID returns the organization with id, asking the organization service on a miss
+func (d *OrgDirectory) ByID(ctx context.Context, id string) (*Organization, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+	if org, ok := d.local(func(i *orgIndex) *Organization { return i.byID[id] }); ok {
+		return org, nil
+	}
+	if d.knownMissing("id:" + id) {
+		return nil, ErrOrganizationNotFound
+	}
+
+	org, err := d.org.GetOrganization(ctx, id)
+	if errors.Is(err, ErrOrganizationNotFound) {
+		d.remember("id:" + id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.add(org, "")
+
+	return copyOrganization(org), nil
+}
+
+// ByName returns the organization whose normalized name is the one of name, asking the organization service on a miss
+func (d *OrgDirectory) ByName(ctx context.Context, name string) (*Organization, error) {
+	normalized := NormalizeName(name)
+	if normalized == "" {
+		return nil, errors.New("ByName: name param is empty")
+	}
+	if org, ok := d.local(func(i *orgIndex) *Organization { return i.byName[normalized] }); ok {
+		return org, nil
+	}
+	if d.knownMissing("name:" + normalized) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// only a definite not found is remembered, not the failed requests
+	org, err := d.org.lookupOrganization(ctx, name)
+	if errors.Is(err, ErrOrganizationNotFound) {
+		d.remember("name:" + normalized)
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the looked up name is also indexed, the service may have resolved an alias
+	d.add(org, normalized)
+
+	return copyOrganization(org), nil
+}
+
+// ByDomain returns the organization owning domain or its closest parent domain, from the index only:
+// the organization service has no lookup by domain. The domains indexed are the ones of the preloaded
+// search results, asked for each organization with DirectoryConfig.LoadDomains, and of the organizations looked up.
+func (d *OrgDirectory) ByDomain(domain string) (*Organization, error) {
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return nil, errors.New("ByDomain: domain param is empty")
+	}
+
+	org, ok := d.local(func(i *orgIndex) *Organization {
+		for candidate := domain; candidate != ""; {
+			if org, ok := i.byDomain[candidate]; ok {
+				return org
+			}
+			dot := strings.Index(candidate, ".")
+			if dot < 0 {
+				break
+			}
+			candidate = candidate[dot+1:]
+		}
+		return nil
+	})
+	if !ok {
+		return nil, ErrOrganizationNotFound
+	}
+
+	return org, nil
+}
+
+// Stats returns the size and activity of the directory
+func (d *OrgDirectory) Stats() DirectoryStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return DirectoryStats{
+		Organizations: len(d.index.byID),
+		Hits:          atomic.LoadInt64(&d.hits),
+		Misses:        atomic.LoadInt64(&d.misses),
+		LoadedAt:      d.loadedAt,
+	}
+}
+
+// local looks find up in the index and counts the hit or miss, the organization returned is a copy
+func (d *OrgDirectory) local(find func(i *orgIndex) *Organization) (*Organization, bool) {
+	d.mu.RLock()
+	org := find(d.index)
+	d.mu.RUnlock()
+
+	if org == nil {
+		atomic.AddInt64(&d.misses, 1)
+		return nil, false
+	}
+	atomic.AddInt64(&d.hits, 1)
+
+	return copyOrganization(org), true
+}
+
+func (d *OrgDirectory) add(org *Organization, alias string) {
+	indexed := copyOrganization(org)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index.add(indexed, alias)
+	if d.loading > 0 {
+		d.added = append(d.added, addedOrganization{org: indexed, alias: alias})
+	}
+}
+
+func (d *OrgDirectory) knownMissing(key string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	at, ok := d.notFound[key]
+	return ok && d.now().Sub(at) < d.config.NegativeTTL
+}
+
+func (d *OrgDirectory) remember(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.notFound[key] = d.now()
+}
+
+func copyOrganization(org *Organization) *Organization {
+	copied := *org
+	copied.Domains = append([]string(nil), org.Domains...)
+	return &copied
+}
+
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if at := strings.LastIndex(domain, "@"); at >= 0 {
+		domain = domain[at+1:]
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(domain, "."), "www.")
+}
@@ -0,0 +1,167 @@
+package orgs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOrgDirectory(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	search := SearchOrganizationResponse{Data: []Organization{
+		{ID: "v03fs-3", Name: "The Linux Foundation", Domains: []string{"linuxfoundation.org"}},
+		{ID: "v03fs-5", Name: "Google LLC", Domains: []string{"Google.com"}},
+	}}
+	search.Metadata.TotalSize = 2
+	searchBytes, _ := json.Marshal(search)
+	cncf, _ := json.Marshal(Organization{ID: "v03fs-7", Name: "Cloud Native Computing Foundation"})
+
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/search?name=foundation&offset=0&pageSize=100", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, searchBytes, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/lookup?name=CNCF", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, cncf, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/lookup?name=Unknown+Corp", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, []byte(`{}`), nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/lookup?name=Flaky+Corp", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(503, []byte("unavailable"), nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/missing", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(404, []byte(`{"message":"not found"}`), nil)
+
+	// the service does not list every organization for an empty name
+	assert.Error(t, NewOrgDirectory(org, DirectoryConfig{}).Preload(ctx))
+
+	directory := NewOrgDirectory(org, DirectoryConfig{Query: "foundation"})
+	assert.NoError(t, directory.Preload(ctx))
+	assert.Equal(t, 2, directory.Stats().Organizations)
+
+	res, err := directory.ByName(ctx, "linux foundation")
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-3", res.ID)
+	res, err = directory.ByID(ctx, "v03fs-5")
+	assert.NoError(t, err)
+	assert.Equal(t, "Google LLC", res.Name)
+	res, err = directory.ByDomain("jdoe@mail.google.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-5", res.ID)
+	_, err = directory.ByDomain("example.com")
+	assert.Equal(t, ErrOrganizationNotFound, err)
+
+	// the misses ask the service once
+	for i := 0; i < 2; i++ {
+		res, err = directory.ByName(ctx, "CNCF")
+		assert.NoError(t, err)
+		assert.Equal(t, "v03fs-7", res.ID)
+		_, err = directory.ByName(ctx, "Unknown Corp")
+		assert.Equal(t, ErrOrganizationNotFound, err)
+		_, err = directory.ByID(ctx, "missing")
+		assert.True(t, errors.Is(err, ErrOrganizationNotFound))
+		// the failures are not remembered as not found
+		_, err = directory.ByName(ctx, "Flaky Corp")
+		var resErr *ResponseError
+		assert.True(t, errors.As(err, &resErr))
+	}
+	res, err = directory.ByID(ctx, "v03fs-7")
+	assert.NoError(t, err)
+	assert.Equal(t, "Cloud Native Computing Foundation", res.Name)
+	httpClient.AssertNumberOfCalls(t, "Request", 0)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 6)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = directory.ByName(canceled, "Other Corp")
+	assert.Equal(t, context.Canceled, err)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 6)
+
+	// the returned organizations are copies
+	res.Name = "changed"
+	res, _ = directory.ByID(ctx, "v03fs-7")
+	assert.Equal(t, "Cloud Native Computing Foundation", res.Name)
+}
+
+func TestOrgDirectoryRefresh(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	search := SearchOrganizationResponse{Data: []Organization{{ID: "v03fs-3", Name: "Linux Foundation"}}}
+	search.Metadata.TotalSize = 1
+	searchBytes, _ := json.Marshal(search)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/search?name=linux&offset=0&pageSize=10", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, searchBytes, nil)
+
+	directory := NewOrgDirectory(org, DirectoryConfig{TTL: 10 * time.Millisecond, PageSize: 10, Query: "linux"})
+	assert.NoError(t, directory.Start(ctx))
+	assert.Error(t, directory.Start(ctx))
+	time.Sleep(50 * time.Millisecond)
+	directory.Stop()
+
+	calls := len(httpClient.Calls)
+	assert.True(t, calls > 2)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, calls, len(httpClient.Calls))
+	assert.False(t, directory.Stats().LoadedAt.IsZero())
+
+	// starting twice does not preload again
+	directory = NewOrgDirectory(org, DirectoryConfig{PageSize: 10, Query: "linux"})
+	assert.NoError(t, directory.Start(ctx))
+	assert.Error(t, directory.Start(ctx))
+	directory.Stop()
+	assert.Equal(t, calls+1, len(httpClient.Calls))
+}
+
+func TestOrgDirectoryKeepsLookupsDuringRefresh(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	search := SearchOrganizationResponse{Data: []Organization{{ID: "v03fs-3", Name: "Linux Foundation"}}}
+	search.Metadata.TotalSize = 1
+	searchBytes, _ := json.Marshal(search)
+	cncf, _ := json.Marshal(Organization{ID: "v03fs-7", Name: "Cloud Native Computing Foundation", Domains: []string{"cncf.io"}})
+
+	directory := NewOrgDirectory(org, DirectoryConfig{Query: "foundation"})
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/lookup?name=CNCF", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, cncf, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/search?name=foundation&offset=0&pageSize=100", "GET", bearer(), []byte(nil), map[string]string(nil)).
+		Run(func(mock.Arguments) {
+			// looked up while the organizations are paged through
+			res, err := directory.ByName(ctx, "CNCF")
+			assert.NoError(t, err)
+			assert.Equal(t, "v03fs-7", res.ID)
+		}).Return(OKStatus, searchBytes, nil)
+
+	assert.NoError(t, directory.Preload(ctx))
+	assert.Equal(t, 2, directory.Stats().Organizations)
+	res, err := directory.ByName(ctx, "CNCF")
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-7", res.ID)
+	res, err = directory.ByDomain("cncf.io")
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-7", res.ID)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 2)
+}
+
+func TestOrgDirectoryLoadDomains(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	// the search results carry no domains
+	search := SearchOrganizationResponse{Data: []Organization{
+		{ID: "v03fs-3", Name: "The Linux Foundation"},
+		{ID: "v03fs-5", Name: "Google LLC"},
+	}}
+	search.Metadata.TotalSize = 2
+	searchBytes, _ := json.Marshal(search)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/search?name=o&offset=0&pageSize=100", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, searchBytes, nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/v03fs-3/domains", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(404, []byte(`{"message":"not found"}`), nil)
+	httpClient.On("RequestContext", ctx, "https://orgs.lfx.dev/orgs/v03fs-5/domains", "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, []byte(`{"Domains":["google.com"]}`), nil)
+
+	directory := NewOrgDirectory(org, DirectoryConfig{Query: "o"})
+	assert.NoError(t, directory.Preload(ctx))
+	_, err := directory.ByDomain("jdoe@google.com")
+	assert.Equal(t, ErrOrganizationNotFound, err)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 1)
+
+	directory = NewOrgDirectory(org, DirectoryConfig{Query: "o", LoadDomains: true})
+	assert.NoError(t, directory.Preload(ctx))
+	res, err := directory.ByDomain("jdoe@google.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-5", res.ID)
+	assert.Equal(t, 2, directory.Stats().Organizations)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 4)
+}
@@ -2,10 +2,11 @@ package orgs
 
 // Organization ...
 type Organization struct {
-	ID      string `json:"ID"`
-	Name    string `json:"Name"`
-	Link    string `json:"Link"`
-	LogoURL string `json:"LogoURL"`
+	ID      string   `json:"ID"`
+	Name    string   `json:"Name"`
+	Link    string   `json:"Link"`
+	LogoURL string   `json:"LogoURL"`
+	Domains []string `json:"Domains,omitempty"`
 }
 
 // SearchOrganizationResponse ...
@@ -17,3 +18,13 @@ type SearchOrganizationResponse struct {
 		TotalSize int `json:"TotalSize"`
 	} `json:"Metadata"`
 }
+
+// DomainsResponse ...
+type DomainsResponse struct {
+	Domains []string `json:"Domains"`
+}
+
+// domainRequest ...
+type domainRequest struct {
+	Domain string `json:"Domain"`
+}
@@ -0,0 +1,27 @@
+package orgs
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrOrganizationNotFound is returned when no organization has the requested id
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrOrganizationConflict is returned when an organization or domain already exists
+	ErrOrganizationConflict = errors.New("organization already exists")
+	// ErrEmptyID is returned when an organization id param is empty
+	ErrEmptyID = errors.New("organization id is empty")
+)
+
+// ResponseError is returned when the organization service answers with an unexpected status
+type ResponseError struct {
+	Operation  string
+	StatusCode int
+	Message    string
+}
+
+// Error ...
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s: unexpected status %d: %s", e.Operation, e.StatusCode, e.Message)
+}
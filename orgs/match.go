@@ -0,0 +1,234 @@
+package orgs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/LF-Engineering/dev-analytics-libraries/uuid"
+)
+
+const (
+	// DefaultMatchThreshold is the minimum similarity of a match
+	DefaultMatchThreshold = 0.85
+	// DefaultMaxAlternatives is the number of alternatives returned with a match
+	DefaultMaxAlternatives = 5
+	// ambiguityMargin is the similarity gap under which the runner-up lowers the confidence of the best match
+	ambiguityMargin = 0.1
+)
+
+// DefaultLegalSuffixes are the legal entity suffixes stripped from the organization names
+var DefaultLegalSuffixes = []string{
+	"ab", "ag", "as", "bv", "co", "company", "corp", "corporation", "gmbh", "inc", "incorporated", "kk",
+	"limited", "llc", "llp", "lp", "ltd", "nv", "oy", "plc", "pte", "pty", "pvt", "sa", "sarl", "sas", "spa", "srl",
+}
+
+var legalSuffixes = func() map[string]struct{} {
+	suffixes := make(map[string]struct{}, len(DefaultLegalSuffixes))
+	for _, s := range DefaultLegalSuffixes {
+		suffixes[s] = struct{}{}
+	}
+	return suffixes
+}()
+
+// NormalizeName folds the case and accents of an organization name, replaces its punctuation by spaces and strips
+// its trailing legal suffixes, so that "Google LLC", "Google, Inc." and "google" are all "google"
+func NormalizeName(name string) string {
+	folded, err := uuid.ToUnicode(name)
+	if err != nil {
+		folded = name
+	}
+	folded = strings.ToLower(strings.Replace(folded, "&", " and ", -1))
+	// dotted abbreviations like "s.a." or "co." are joined before the punctuation is dropped
+	folded = strings.Replace(folded, ".", "", -1)
+
+	tokens := strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(tokens) > 1 && tokens[0] == "the" {
+		tokens = tokens[1:]
+	}
+	for len(tokens) > 1 {
+		if _, ok := legalSuffixes[tokens[len(tokens)-1]]; !ok {
+			break
+		}
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// Similarity scores the similarity of two organization names from 0 to 1, 1 meaning equal once normalized.
+// It is the Jaro-Winkler similarity of the names with sorted words, so that the word order does not matter.
+func Similarity(a, b string) float64 {
+	return similarity(NormalizeName(a), NormalizeName(b))
+}
+
+func similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	return jaroWinkler(sortedWords(a), sortedWords(b))
+}
+
+func sortedWords(s string) string {
+	words := strings.Fields(s)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// jaroWinkler ...
+func jaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) > len(s2) {
+		s1, s2 = s2, s1
+	}
+	window := len(s2)/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		lo, hi := i-window, i+window+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(s2) {
+			hi = len(s2)
+		}
+		for j := lo; j < hi; j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions, j := 0, 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < len(s1) && prefix < 4 && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// MatchConfig configures MatchOrganization, the zero values meaning the defaults
+type MatchConfig struct {
+	Threshold       float64
+	MaxAlternatives int
+	PageSize        int
+}
+
+// Match is a candidate organization and its similarity to the searched name
+type Match struct {
+	Organization Organization
+	Score        float64
+}
+
+// MatchResult is the outcome of a fuzzy match. Best is nil when no candidate reaches the threshold.
+// Confidence is the score of Best, lowered when the runner-up is almost as similar.
+type MatchResult struct {
+	Name         string
+	Normalized   string
+	Best         *Match
+	Confidence   float64
+	Alternatives []Match
+}
+
+// MatchOrganization searches the organizations similar to name and returns the best match and the alternatives.
+// The service is searched with name as given, and with its normalized form when it differs,
+// since the service does not fold the accents nor strip the legal suffixes itself.
+func (o *Org) MatchOrganization(ctx context.Context, name string, config MatchConfig) (*MatchResult, error) {
+	normalized := NormalizeName(name)
+	if normalized == "" {
+		return nil, errors.New("MatchOrganization: name param is empty")
+	}
+
+	terms := []string{strings.TrimSpace(name)}
+	if !strings.EqualFold(terms[0], normalized) {
+		terms = append(terms, normalized)
+	}
+	var candidates []Organization
+	seen := make(map[string]struct{})
+	for _, term := range terms {
+		res, err := o.SearchOrganizations(ctx, term, Page{Size: config.PageSize})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range res.Data {
+			if _, ok := seen[c.ID]; ok {
+				continue
+			}
+			seen[c.ID] = struct{}{}
+			candidates = append(candidates, c)
+		}
+	}
+
+	return RankOrganizations(name, candidates, config), nil
+}
+
+// RankOrganizations scores the candidates against name, the best first
+func RankOrganizations(name string, candidates []Organization, config MatchConfig) *MatchResult {
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultMatchThreshold
+	}
+	if config.MaxAlternatives <= 0 {
+		config.MaxAlternatives = DefaultMaxAlternatives
+	}
+
+	result := &MatchResult{Name: name, Normalized: NormalizeName(name)}
+	matches := make([]Match, 0, len(candidates))
+	for _, c := range candidates {
+		if score := similarity(result.Normalized, NormalizeName(c.Name)); score > 0 {
+			matches = append(matches, Match{Organization: c, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	if len(matches) > 0 && matches[0].Score >= config.Threshold {
+		best := matches[0]
+		result.Best = &best
+		result.Confidence = best.Score
+		if len(matches) > 1 {
+			if gap := best.Score - matches[1].Score; gap < ambiguityMargin {
+				result.Confidence -= (ambiguityMargin - gap) / 2
+			}
+		}
+		matches = matches[1:]
+	}
+	if len(matches) > config.MaxAlternatives {
+		matches = matches[:config.MaxAlternatives]
+	}
+	result.Alternatives = matches
+
+	return result
+}
@@ -0,0 +1,94 @@
+package orgs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Google LLC":              "google",
+		"Google, Inc.":            "google",
+		"google":                  "google",
+		"  The Linux Foundation ": "linux foundation",
+		"Société Générale S.A.":   "societe generale",
+		"Procter & Gamble Co.":    "procter and gamble",
+		"Inc":                     "inc",
+		"Red Hat, Inc":            "red hat",
+	} {
+		assert.Equal(t, expected, NormalizeName(name), name)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, Similarity("Google LLC", "google, inc."))
+	assert.Equal(t, 1.0, Similarity("Foundation Linux", "The Linux Foundation"))
+	assert.True(t, Similarity("Microsoft Corp", "Microsft") > 0.9)
+	assert.True(t, Similarity("Google", "Amazon") < 0.6)
+	assert.Equal(t, 0.0, Similarity("", "Google"))
+}
+
+func TestRankOrganizations(t *testing.T) {
+	candidates := []Organization{
+		{ID: "1", Name: "Alphabet Inc."},
+		{ID: "2", Name: "Google LLC"},
+		{ID: "3", Name: "Googler Club"},
+	}
+
+	result := RankOrganizations("google, inc.", candidates, MatchConfig{})
+	assert.Equal(t, "google", result.Normalized)
+	assert.Equal(t, "2", result.Best.Organization.ID)
+	assert.Equal(t, 1.0, result.Confidence)
+	assert.Equal(t, "3", result.Alternatives[0].Organization.ID)
+
+	// a close runner-up lowers the confidence
+	result = RankOrganizations("Linux Foundation", []Organization{{ID: "1", Name: "Linux Foundations"}, {ID: "2", Name: "Linux Foundation"}}, MatchConfig{})
+	assert.Equal(t, "2", result.Best.Organization.ID)
+	assert.True(t, result.Confidence < 1)
+
+	result = RankOrganizations("Amazon", candidates, MatchConfig{MaxAlternatives: 1})
+	assert.Nil(t, result.Best)
+	assert.Len(t, result.Alternatives, 1)
+}
+
+func TestMatchOrganization(t *testing.T) {
+	org, httpClient := newCrudOrg()
+	ctx := context.Background()
+	response := func(orgs ...Organization) []byte {
+		var search SearchOrganizationResponse
+		search.Data = orgs
+		data, _ := json.Marshal(search)
+		return data
+	}
+	endpoint := "https://orgs.lfx.dev/orgs/search?name=%s&offset=0&pageSize=100"
+	google := Organization{ID: "v03fs-3", Name: "Google LLC"}
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, "Google%2C+Inc."), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, response(), nil)
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, "google"), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, response(google), nil)
+
+	result, err := org.MatchOrganization(ctx, "Google, Inc.", MatchConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-3", result.Best.Organization.ID)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 2)
+
+	// a name already normalized is searched once
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, "Google"), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, response(google), nil)
+	_, err = org.MatchOrganization(ctx, "Google", MatchConfig{})
+	assert.NoError(t, err)
+	httpClient.AssertNumberOfCalls(t, "RequestContext", 3)
+
+	// the service only finds the accented name as given, the candidates found twice are ranked once
+	societe := Organization{ID: "v03fs-5", Name: "Société Générale S.A."}
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, "Soci%C3%A9t%C3%A9+G%C3%A9n%C3%A9rale"), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, response(societe, google), nil)
+	httpClient.On("RequestContext", ctx, fmt.Sprintf(endpoint, "societe+generale"), "GET", bearer(), []byte(nil), map[string]string(nil)).Return(OKStatus, response(google), nil)
+	result, err = org.MatchOrganization(ctx, " Société Générale ", MatchConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, "v03fs-5", result.Best.Organization.ID)
+	assert.Len(t, result.Alternatives, 1)
+
+	_, err = org.MatchOrganization(ctx, " , ", MatchConfig{})
+	assert.Error(t, err)
+}
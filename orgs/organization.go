@@ -1,6 +1,7 @@
 package orgs
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -25,6 +26,13 @@ type HTTPClientProvider interface {
 	Request(string, string, map[string]string, []byte, map[string]string) (int, []byte, error)
 }
 
+// ContextHTTPClientProvider is a HTTPClientProvider whose requests can be bound to a context, like http.ClientProvider.
+// The requests of the other providers are sent without the context.
+type ContextHTTPClientProvider interface {
+	HTTPClientProvider
+	RequestContext(context.Context, string, string, map[string]string, []byte, map[string]string) (int, []byte, error)
+}
+
 // ESClientProvider ...
 type ESClientProvider interface {
 	CreateDocument(index, documentID string, body []byte) ([]byte, error)
@@ -33,7 +41,7 @@ type ESClientProvider interface {
 	Get(index string, query map[string]interface{}, result interface{}) error
 }
 
-// SlackProvider ...
+// SlackProvider receives alerts, a slack.Provider or any alert.Alerter
 type SlackProvider interface {
 	SendText(text string) error
 }
@@ -57,18 +65,17 @@ type Org struct {
 }
 
 // SearchOrganization ...
+// Use SearchOrganizations for typed pagination, or Organizations to walk every page.
 func (o *Org) SearchOrganization(name string, pageSize string, offset string) (*SearchOrganizationResponse, error) {
 	if name == "" {
 		log.Println("SearchOrganization: name param is empty")
 		return nil, errors.New("SearchOrganization: name param is empty")
 	}
-	token, err := o.auth0Client.GetToken()
+	headers, err := http.BearerHeader(o.auth0Client)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	if offset == "" {
 		offset = "0"
@@ -98,13 +105,11 @@ func (o *Org) LookupOrganization(name string) (*Organization, error) {
 		log.Println("LookupOrganization: name param is empty")
 		return nil, errors.New("LookupOrganization: name param is empty")
 	}
-	token, err := o.auth0Client.GetToken()
+	headers, err := http.BearerHeader(o.auth0Client)
 	if err != nil {
 		log.Println(err)
 		return nil, err
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	endpoint := o.OrgBaseURL + "/lookup?name=" + url.QueryEscape(name)
 	_, res, err := o.httpClient.Request(strings.TrimSpace(endpoint), "GET", headers, nil, nil)
@@ -122,6 +127,61 @@ func (o *Org) LookupOrganization(name string) (*Organization, error) {
 	return &response, nil
 }
 
+// SetRateLimiter limits the requests of the client with limiter, which may be shared with other clients calling the same hosts.
+// It returns http.ErrNotClientProvider when the http client cannot be limited.
+func (o *Org) SetRateLimiter(limiter *http.RateLimiter) error {
+	p, ok := o.httpClient.(*http.ClientProvider)
+	if !ok {
+		return fmt.Errorf("SetRateLimiter: %w", http.ErrNotClientProvider)
+	}
+	p.SetRateLimiter(limiter)
+	return nil
+}
+
+// SetCache caches the responses of the client in cache, which may be shared with other clients.
+// It returns http.ErrNotClientProvider when the http client cannot cache.
+func (o *Org) SetCache(cache *http.Cache) error {
+	p, ok := o.httpClient.(*http.ClientProvider)
+	if !ok {
+		return fmt.Errorf("SetCache: %w", http.ErrNotClientProvider)
+	}
+	p.SetCache(cache)
+	return nil
+}
+
+// Use adds middlewares to the requests of the client, to log them or record their metrics for example.
+// It returns http.ErrNotClientProvider when the http client has no middlewares.
+func (o *Org) Use(middlewares ...http.Middleware) error {
+	p, ok := o.httpClient.(*http.ClientProvider)
+	if !ok {
+		return fmt.Errorf("Use: %w", http.ErrNotClientProvider)
+	}
+	p.Use(middlewares...)
+	return nil
+}
+
+// alertsCloser is the alerts handling of auth0.ClientProvider
+type alertsCloser interface {
+	FlushAlerts(timeout time.Duration) error
+	Close(timeout time.Duration) error
+}
+
+// FlushAlerts waits until the pending alerts of the auth0 client are sent
+func (o *Org) FlushAlerts(timeout time.Duration) error {
+	if c, ok := o.auth0Client.(alertsCloser); ok {
+		return c.FlushAlerts(timeout)
+	}
+	return nil
+}
+
+// Close sends the pending alerts and stops the alert dispatcher of the auth0 client, call it on shutdown
+func (o *Org) Close(timeout time.Duration) error {
+	if c, ok := o.auth0Client.(alertsCloser); ok {
+		return c.Close(timeout)
+	}
+	return nil
+}
+
 // NewClient consumes
 // orgBaseURL, esCacheUrl, esCacheUsername, esCachePassword, esCacheIndex, env, authGrantType, authClientID, authClientSecret, authAudience, authURL
 func NewClient(orgBaseURL, esCacheURL, esCacheUsername,
@@ -3,11 +3,14 @@ package orgs
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/LF-Engineering/dev-analytics-libraries/http"
 	"github.com/LF-Engineering/dev-analytics-libraries/orgs/mocks"
 	"github.com/stretchr/testify/assert"
 )
@@ -135,3 +138,68 @@ func TestSearchOrganizationSpecialChars(t *testing.T) {
 	assert.Equal(t, actualResponse.Data[0].Name, "ą ę jest ż")
 	assert.Equal(t, actualResponse.Data[0].ID, "v03fs-7")
 }
+
+func TestLookupOrganizationReplay(t *testing.T) {
+	recorder, err := http.NewRecorder("testdata/lookup_organization.json", http.RecorderConfig{Matching: http.MatchStrict})
+	assert.NoError(t, err)
+	httpClient := http.NewClientProvider(time.Second)
+	httpClient.SetRecorder(recorder)
+
+	auth0Client := &mocks.Auth0ClientProvider{}
+	auth0Client.On("GetToken").Return(token, nil)
+	org := &Org{
+		OrgBaseURL:  "https://api.lfx.dev/orgs-service",
+		httpClient:  httpClient,
+		auth0Client: auth0Client,
+	}
+
+	actualResponse, err := org.LookupOrganization("linux")
+	assert.NoError(t, err)
+	assert.Equal(t, "Linux Foundation, US", actualResponse.Name)
+	assert.Equal(t, "v03fs-3", actualResponse.ID)
+}
+
+// closingAuth0Client records the alerts handling calls made by the client
+type closingAuth0Client struct {
+	flushed bool
+	closed  bool
+}
+
+func (c *closingAuth0Client) GetToken() (string, error) {
+	return token, nil
+}
+
+func (c *closingAuth0Client) FlushAlerts(timeout time.Duration) error {
+	c.flushed = true
+	return nil
+}
+
+func (c *closingAuth0Client) Close(timeout time.Duration) error {
+	c.closed = true
+	return nil
+}
+
+func TestOrgClose(t *testing.T) {
+	auth0Client := &closingAuth0Client{}
+	client := &Org{auth0Client: auth0Client}
+	assert.NoError(t, client.FlushAlerts(time.Second))
+	assert.True(t, auth0Client.flushed)
+	assert.NoError(t, client.Close(time.Second))
+	assert.True(t, auth0Client.closed)
+
+	// the auth0 clients without alerts have nothing to close
+	client = &Org{auth0Client: &mocks.Auth0ClientProvider{}}
+	assert.NoError(t, client.Close(time.Second))
+}
+
+func TestOrgHTTPSettings(t *testing.T) {
+	client := &Org{httpClient: &mocks.HTTPClientProvider{}}
+	assert.True(t, errors.Is(client.SetRateLimiter(http.NewRateLimiter(http.HostLimit{})), http.ErrNotClientProvider))
+	assert.True(t, errors.Is(client.SetCache(http.NewCache(http.NewMemoryCache(0))), http.ErrNotClientProvider))
+	assert.True(t, errors.Is(client.Use(), http.ErrNotClientProvider))
+
+	client = &Org{httpClient: http.NewClientProvider(time.Second)}
+	assert.NoError(t, client.SetRateLimiter(http.NewRateLimiter(http.HostLimit{})))
+	assert.NoError(t, client.SetCache(http.NewCache(http.NewMemoryCache(0))))
+	assert.NoError(t, client.Use())
+}
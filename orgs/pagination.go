@@ -0,0 +1,121 @@
+package orgs
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPageSize is the page size of the searches without one
+const DefaultPageSize = 100
+
+// Page selects a page of results, Offset being the page number starting at 0
+type Page struct {
+	Size   int
+	Offset int
+}
+
+// SearchOrganizations returns a page of the organizations matching name
+func (o *Org) SearchOrganizations(ctx context.Context, name string, page Page) (*SearchOrganizationResponse, error) {
+	if page.Size <= 0 {
+		page.Size = DefaultPageSize
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("pageSize", strconv.Itoa(page.Size))
+	query.Set("offset", strconv.Itoa(page.Offset))
+
+	var response SearchOrganizationResponse
+	if err := o.call(ctx, "SearchOrganizations", nethttp.MethodGet, "/orgs/search?"+query.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// OrganizationIterator walks every page of a search, fetching the next page when the current one is consumed
+type OrganizationIterator struct {
+	org      *Org
+	ctx      context.Context
+	name     string
+	page     Page
+	buffer   []Organization
+	current  Organization
+	seen     int
+	total    int
+	finished bool
+	err      error
+}
+
+// Organizations returns an iterator over the organizations matching name, fetched pageSize at a time
+func (o *Org) Organizations(ctx context.Context, name string, pageSize int) *OrganizationIterator {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &OrganizationIterator{org: o, ctx: ctx, name: name, page: Page{Size: pageSize}}
+}
+
+// Next moves to the next organization, it returns false once every page was read or on error
+func (it *OrganizationIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	for len(it.buffer) == 0 {
+		if it.finished {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+
+	it.current, it.buffer = it.buffer[0], it.buffer[1:]
+	it.seen++
+	return true
+}
+
+func (it *OrganizationIterator) fetch() error {
+	res, err := it.org.SearchOrganizations(it.ctx, it.name, it.page)
+	if err != nil {
+		return err
+	}
+
+	it.total = res.Metadata.TotalSize
+	it.buffer = res.Data
+	it.page.Offset++
+	// an empty or short page also ends the walk, should TotalSize be missing or wrong
+	if len(res.Data) < it.page.Size || (it.total > 0 && it.seen+len(res.Data) >= it.total) {
+		it.finished = true
+	}
+
+	return nil
+}
+
+// Organization returns the current organization
+func (it *OrganizationIterator) Organization() Organization {
+	return it.current
+}
+
+// Total returns the TotalSize of the search, known once the first page is fetched, zero when the service does not report it
+func (it *OrganizationIterator) Total() int {
+	return it.total
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *OrganizationIterator) Err() error {
+	return it.err
+}
+
+// All returns every remaining organization
+func (it *OrganizationIterator) All() ([]Organization, error) {
+	var all []Organization
+	for it.Next() {
+		all = append(all, it.Organization())
+	}
+	return all, it.Err()
+}
@@ -33,7 +33,7 @@ type ESClientProvider interface {
 	Get(index string, query map[string]interface{}, result interface{}) error
 }
 
-// SlackProvider ...
+// SlackProvider receives alerts, a slack.Provider or any alert.Alerter
 type SlackProvider interface {
 	SendText(text string) error
 }
@@ -58,13 +58,11 @@ type Client struct {
 
 // List ...
 func (u *Client) List(email string, pageSize string, offset string) (*ListResponse, error) {
-	token, err := u.auth0Client.GetToken()
+	headers, err := http.BearerHeader(u.auth0Client)
 	if err != nil {
 		log.Println("users.List", err)
 		return nil, err
 	}
-	headers := make(map[string]string, 0)
-	headers["Authorization"] = fmt.Sprintf("%s %s", "Bearer", token)
 
 	if offset == "" {
 		offset = "0"
@@ -116,6 +114,61 @@ func (u *Client) List(email string, pageSize string, offset string) (*ListRespon
 	return &response, nil
 }
 
+// SetRateLimiter limits the requests of the client with limiter, which may be shared with other clients calling the same hosts.
+// It returns http.ErrNotClientProvider when the http client cannot be limited.
+func (u *Client) SetRateLimiter(limiter *http.RateLimiter) error {
+	p, ok := u.httpClient.(*http.ClientProvider)
+	if !ok {
+		return fmt.Errorf("SetRateLimiter: %w", http.ErrNotClientProvider)
+	}
+	p.SetRateLimiter(limiter)
+	return nil
+}
+
+// SetCache caches the responses of the client in cache, which may be shared with other clients.
+// It returns http.ErrNotClientProvider when the http client cannot cache.
+func (u *Client) SetCache(cache *http.Cache) error {
+	p, ok := u.httpClient.(*http.ClientProvider)
+	if !ok {
+		return fmt.Errorf("SetCache: %w", http.ErrNotClientProvider)
+	}
+	p.SetCache(cache)
+	return nil
+}
+
+// Use adds middlewares to the requests of the client, to log them or record their metrics for example.
+// It returns http.ErrNotClientProvider when the http client has no middlewares.
+func (u *Client) Use(middlewares ...http.Middleware) error {
+	p, ok := u.httpClient.(*http.ClientProvider)
+	if !ok {
+		return fmt.Errorf("Use: %w", http.ErrNotClientProvider)
+	}
+	p.Use(middlewares...)
+	return nil
+}
+
+// alertsCloser is the alerts handling of auth0.ClientProvider
+type alertsCloser interface {
+	FlushAlerts(timeout time.Duration) error
+	Close(timeout time.Duration) error
+}
+
+// FlushAlerts waits until the pending alerts of the auth0 client are sent
+func (u *Client) FlushAlerts(timeout time.Duration) error {
+	if c, ok := u.auth0Client.(alertsCloser); ok {
+		return c.FlushAlerts(timeout)
+	}
+	return nil
+}
+
+// Close sends the pending alerts and stops the alert dispatcher of the auth0 client, call it on shutdown
+func (u *Client) Close(timeout time.Duration) error {
+	if c, ok := u.auth0Client.(alertsCloser); ok {
+		return c.Close(timeout)
+	}
+	return nil
+}
+
 // NewClient consumes
 // userBaseURL, esCacheUrl, esCacheUsername, esCachePassword, esCacheIndex, env, authGrantType, authClientID, authClientSecret, authAudience, authURL
 func NewClient(userBaseURL, esCacheURL, esCacheUsername,
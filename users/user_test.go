@@ -2,11 +2,14 @@ package users
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/LF-Engineering/dev-analytics-libraries/http"
 	"github.com/LF-Engineering/dev-analytics-libraries/users/mocks"
 	"github.com/stretchr/testify/assert"
 
@@ -87,3 +90,48 @@ func TestList(t *testing.T) {
 	assert.Equal(t, true, actualResponse.Data[0].Emails[0].IsVerified)
 	assert.Equal(t, "lgryglicki@cncf.io", actualResponse.Data[0].Email)
 }
+
+// closingAuth0Client records the alerts handling calls made by the client
+type closingAuth0Client struct {
+	flushed bool
+	closed  bool
+}
+
+func (c *closingAuth0Client) GetToken() (string, error) {
+	return token, nil
+}
+
+func (c *closingAuth0Client) FlushAlerts(timeout time.Duration) error {
+	c.flushed = true
+	return nil
+}
+
+func (c *closingAuth0Client) Close(timeout time.Duration) error {
+	c.closed = true
+	return nil
+}
+
+func TestClientClose(t *testing.T) {
+	auth0Client := &closingAuth0Client{}
+	client := &Client{auth0Client: auth0Client}
+	assert.NoError(t, client.FlushAlerts(time.Second))
+	assert.True(t, auth0Client.flushed)
+	assert.NoError(t, client.Close(time.Second))
+	assert.True(t, auth0Client.closed)
+
+	// the auth0 clients without alerts have nothing to close
+	client = &Client{auth0Client: &mocks.Auth0ClientProvider{}}
+	assert.NoError(t, client.Close(time.Second))
+}
+
+func TestClientHTTPSettings(t *testing.T) {
+	client := &Client{httpClient: &mocks.HTTPClientProvider{}}
+	assert.True(t, errors.Is(client.SetRateLimiter(http.NewRateLimiter(http.HostLimit{})), http.ErrNotClientProvider))
+	assert.True(t, errors.Is(client.SetCache(http.NewCache(http.NewMemoryCache(0))), http.ErrNotClientProvider))
+	assert.True(t, errors.Is(client.Use(), http.ErrNotClientProvider))
+
+	client = &Client{httpClient: http.NewClientProvider(time.Second)}
+	assert.NoError(t, client.SetRateLimiter(http.NewRateLimiter(http.HostLimit{})))
+	assert.NoError(t, client.SetCache(http.NewCache(http.NewMemoryCache(0))))
+	assert.NoError(t, client.Use())
+}